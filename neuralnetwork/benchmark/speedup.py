@@ -12,7 +12,7 @@ sum = 0
 for test in tests:
     for _ in range(5):
         result = subprocess.run(['go', 'run', 'proj3/editor', test, 's'], stdout=subprocess.PIPE)
-        seconds = float(result.stdout.decode('utf-8'))
+        seconds = float(result.stdout.decode('utf-8').split()[-1]) # elapsed seconds are the last line
         sum += seconds
     avgs[test + ' ' + 's'] = sum / 5
 
@@ -28,7 +28,7 @@ for threads in threadcounts:
             for _ in range(5): # run each test 5 times
                 result = subprocess.run(['go', 'run', 'proj3/editor', test, parallelversion, threads], stdout=subprocess.PIPE)
                 try:
-                    seconds = float(result.stdout.decode('utf-8'))
+                    seconds = float(result.stdout.decode('utf-8').split()[-1]) # elapsed seconds are the last line
                 except:
                     pass
                 sum += seconds
@@ -0,0 +1,45 @@
+import matplotlib.pyplot as plt
+import subprocess
+import json
+import os
+
+# test accuracy of the ensemble modes vs how often the chunks' networks are averaged (-sync k)
+# k = 0 is the original behavior: independent networks averaged once at the end
+
+epochs = '25'
+threads = '8'
+intervals = [0, 1, 2, 5, 10, 25]
+parallelversions = ['ws', 'wb']
+runs = 3
+report = 'sync-report.json'
+
+accs = {}
+
+for parallelversion in parallelversions:
+    for k in intervals:
+        sum = 0
+        for _ in range(runs):
+            subprocess.run(['go', 'run', 'proj3/editor', '-sync', str(k), '-json', report, epochs, parallelversion, threads], stdout=subprocess.PIPE)
+            with open(report) as f:
+                sum += json.load(f)['accuracy']
+        accs[parallelversion + ' ' + str(k)] = sum / runs
+
+os.remove(report)
+
+# table
+
+print('sync every (epochs)  ' + '  '.join(parallelversions))
+for k in intervals:
+    print('%-20s' % ('end' if k == 0 else k) + '  '.join('%.4f' % accs[v + ' ' + str(k)] for v in parallelversions))
+
+# graphing and saving
+
+plt.title('Test Accuracy vs Sync Interval (' + epochs + ' epochs, ' + threads + ' threads)')
+labels = ['end' if k == 0 else str(k) for k in intervals]
+plt.plot(labels, [accs['ws ' + str(k)] for k in intervals], 'b', label='work stealing')
+plt.plot(labels, [accs['wb ' + str(k)] for k in intervals], 'g', label='work balancing')
+plt.legend(loc='lower left')
+plt.ylabel('Test Accuracy')
+plt.xlabel('Sync Every (epochs)')
+plt.savefig('sync-interval')
+#plt.show()
@@ -3,17 +3,20 @@ package concurrent
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 )
 
 type WorkBalancingExecutor struct {
-	capacity int
-	tasks    int
-	index    int
-	shutdown bool
-	wg       *sync.WaitGroup
-	lock     *sync.Mutex
-	deques   []DEQueue
-	balance  int
+	capacity     int
+	tasks        int64 // submitted tasks that haven't finished running; updated atomically
+	index        int
+	shutdown     int32 // set to 1 (atomically) by Shutdown
+	wg           *sync.WaitGroup
+	lock         *sync.Mutex  // only serializes Submit and Shutdown; workers never take it
+	balanceLocks []sync.Mutex // one per deque; a balancing pair is locked lower index first
+	deques       []DEQueue
+	balance      int
+	idler        *idler
 }
 
 // NewWorkBalancingExecutor returns an ExecutorService that is implemented using the work-balancing algorithm.
@@ -27,7 +30,10 @@ type WorkBalancingExecutor struct {
 // balancing. Remember, if two local queues are to be balanced the
 // difference in the sizes of the queues must be greater than or equal to
 // thresholdBalance. You must use this parameter in your implementation.
-func NewWorkBalancingExecutor(capacity, thresholdQueue, thresholdBalance int) ExecutorService {
+// @param opts - optional settings, e.g. WithIdleStrategy to trade CPU time for latency while workers wait for tasks
+func NewWorkBalancingExecutor(capacity, thresholdQueue, thresholdBalance int, opts ...ExecutorOption) ExecutorService {
+	options := newExecutorOptions(opts)
+
 	// create an array of deques - one for each thread
 	deque := make([]DEQueue, capacity)
 	for i := 0; i < capacity; i++ {
@@ -36,13 +42,15 @@ func NewWorkBalancingExecutor(capacity, thresholdQueue, thresholdBalance int) Ex
 
 	// create the executor
 	executor := &WorkBalancingExecutor{
-		capacity: capacity,
-		tasks:    0,
-		index:    0,
-		wg:       &sync.WaitGroup{},
-		lock:     &sync.Mutex{},
-		deques:   deque,
-		balance:  thresholdBalance,
+		capacity:     capacity,
+		tasks:        0,
+		index:        0,
+		wg:           &sync.WaitGroup{},
+		lock:         &sync.Mutex{},
+		balanceLocks: make([]sync.Mutex, capacity),
+		deques:       deque,
+		balance:      thresholdBalance,
+		idler:        newIdler(options.idle),
 	}
 
 	executor.BeginExecutor() // launch the threads
@@ -58,72 +66,105 @@ func (executor *WorkBalancingExecutor) BeginExecutor() {
 	}
 }
 
+// accepts a Runnable or a Callable and returns a Future for it
+// tasks are handed out to the local deques round-robin
 func (executor *WorkBalancingExecutor) Submit(task interface{}) Future {
 	executor.lock.Lock()
 	defer executor.lock.Unlock()
+	if atomic.LoadInt32(&executor.shutdown) == 1 {
+		return nil // submits after Shutdown are ignored
+	}
+	ft := newFutureTask(task)
 	executor.index = executor.index % executor.capacity
-	executor.deques[executor.index].PushBottom(task) // add task to the end of the deque
-	executor.tasks++
+	atomic.AddInt64(&executor.tasks, 1)            // count the task before any worker can see it
+	executor.deques[executor.index].PushBottom(ft) // add task to the end of the deque
 	executor.index++
-	return nil // nil future
+	executor.idler.wake()
+	return ft.future
 }
 
+// all tasks are submitted before Shutdown, so once a worker sees the shutdown flag
+// the task count can only go down; workers exit when it reaches 0
 func (executor *WorkBalancingExecutor) Shutdown() {
 	executor.lock.Lock()
-	executor.shutdown = true
+	atomic.StoreInt32(&executor.shutdown, 1)
 	executor.lock.Unlock()
-	executor.wg.Wait() // wait until all goroutines are done
+	executor.idler.wake() // parked workers need to recheck whether they're done
+	executor.wg.Wait()    // wait until all goroutines are done
+}
+
+// true once Shutdown has been called and every submitted task has finished
+func (executor *WorkBalancingExecutor) done() bool {
+	return atomic.LoadInt32(&executor.shutdown) == 1 && atomic.LoadInt64(&executor.tasks) == 0
 }
 
 // this was taken pretty much straight from the art of multiprocessing textbook
 // run tasks; threadId refers to the local deque the thread will be operating on
 func (executor *WorkBalancingExecutor) BalancingWorker(threadId int) {
 	defer executor.wg.Done()
+	idle := idleState{}
 	// there will be a gap between when this loop starts iterating and tasks are submitted
 	// our "shutdown" variable prevents this loop from terminating before all tasks have been submitted
 	for {
+		epoch := executor.idler.Epoch() // read before looking so a Submit in the meantime stops us parking
 		size := executor.deques[threadId].Size()
-		if executor.deques[threadId].IsEmpty() {
-			if executor.shutdown && executor.tasks == 0 {
+		if size == 0 {
+			if executor.done() {
 				return // no more work to do
 			}
-		} else if rand.Intn(executor.deques[threadId].Size()+1) == size {
-			victim := rand.Intn(executor.capacity)
-			var min int
-			var max int
-			if victim > threadId {
-				min = threadId
-				max = victim
-			} else {
-				min = victim
-				max = threadId
-			}
-
-			var qMin DEQueue
-			var qMax DEQueue
-			if executor.deques[min].Size() > executor.deques[max].Size() {
-				qMin = executor.deques[min]
-				qMax = executor.deques[max]
-			} else {
-				qMin = executor.deques[min]
-				qMax = executor.deques[max]
+			// only Submit or another worker's balancing can give us work
+			executor.idler.wait(&idle, epoch, executor.deques[threadId].IsEmpty)
+		} else if rand.Intn(size+1) == size {
+			if executor.balanceWith(threadId, rand.Intn(executor.capacity)) {
+				executor.idler.wake() // the victim may be parked on an empty deque
 			}
-			diff := qMax.Size() - qMin.Size()
-
-			if diff > executor.balance {
-				for qMax.Size() > qMin.Size() {
-					qMin.PushBottom(qMax.PopTop())
+		} else {
+			// there is work to do; run it without holding any shared lock
+			// PopTop returns nil if a balancing thread moved our last task away
+			if task, ok := executor.deques[threadId].PopTop().(Runnable); ok {
+				idle.reset()
+				task.Run()
+				if atomic.AddInt64(&executor.tasks, -1) == 0 {
+					executor.idler.wake() // parked workers may be able to exit now
 				}
 			}
-
-		} else {
-			// there is work to do
-			task, _ := executor.deques[threadId].PopTop().(Runnable)
-			executor.lock.Lock()
-			task.Run()
-			executor.tasks--
-			executor.lock.Unlock()
 		}
+	}
+}
+
+// evens out the sizes of two deques if they differ by more than the balance threshold
+// the pair is locked in index order so two balancing threads can't deadlock or fight over the same deques
+// returns true if any tasks were moved
+func (executor *WorkBalancingExecutor) balanceWith(threadId, victim int) bool {
+	if victim == threadId {
+		return false
+	}
+	min, max := threadId, victim
+	if victim < threadId {
+		min, max = victim, threadId
+	}
+	executor.balanceLocks[min].Lock()
+	defer executor.balanceLocks[min].Unlock()
+	executor.balanceLocks[max].Lock()
+	defer executor.balanceLocks[max].Unlock()
 
+	qMin := executor.deques[min]
+	qMax := executor.deques[max]
+	if qMin.Size() > qMax.Size() {
+		qMin, qMax = qMax, qMin
+	}
+	diff := qMax.Size() - qMin.Size()
+
+	moved := false
+	if diff > executor.balance {
+		for qMax.Size() > qMin.Size() {
+			task := qMax.PopTop()
+			if task == nil {
+				break // the owner ran the rest in the meantime
+			}
+			qMin.PushBottom(task)
+			moved = true
+		}
 	}
+	return moved
 }
@@ -0,0 +1,137 @@
+package concurrent
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// the following is the Chase-Lev work-stealing deque (Chase & Lev, "Dynamic Circular Work-Stealing Deque")
+// with the memory ordering fixes from Lê et al., "Correct and Efficient Work-Stealing for Weak Memory Models"
+// go's atomics are sequentially consistent so we don't need any explicit fences
+//
+// the owner pushes and pops at the bottom without taking a lock
+// thieves pop from the top and race each other (and the owner, for the last task) with a CAS on top
+// PushBottom and PopBottom must only ever be called by the goroutine that owns the deque
+// PopTop, Size and IsEmpty are safe from any goroutine
+// PopTop and PopBottom return nil if the deque is empty or the task was lost to another goroutine
+
+const chaseLevInitialSize = 32
+
+func NewChaseLevDEQueue() DEQueue {
+	q := &ChaseLevDEQueue{}
+	atomic.StorePointer(&q.array, unsafe.Pointer(newCircularArray(chaseLevInitialSize)))
+	return q
+}
+
+type ChaseLevDEQueue struct {
+	top    int64          // next index to steal from; only ever increases
+	bottom int64          // next index the owner will push to
+	array  unsafe.Pointer // *circularArray; replaced (never shrunk) when the deque grows
+}
+
+// a power of two sized ring buffer
+// slots hold *Task so that thieves can read them atomically while the owner writes
+type circularArray struct {
+	mask  int64
+	slots []unsafe.Pointer
+}
+
+func newCircularArray(size int64) *circularArray {
+	return &circularArray{
+		mask:  size - 1,
+		slots: make([]unsafe.Pointer, size),
+	}
+}
+
+func (a *circularArray) size() int64 {
+	return int64(len(a.slots))
+}
+
+// returns nil for a slot that was never written
+// a thief can read a stale top, then load an array the owner grew after another thief moved top past it;
+// grow only copies [top, bottom), so the thief's index may land on an empty slot (its CAS on top then fails)
+func (a *circularArray) get(i int64) Task {
+	slot := (*Task)(atomic.LoadPointer(&a.slots[i&a.mask]))
+	if slot == nil {
+		return nil
+	}
+	return *slot
+}
+
+func (a *circularArray) put(i int64, task Task) {
+	atomic.StorePointer(&a.slots[i&a.mask], unsafe.Pointer(&task))
+}
+
+// returns a copy of the array with double the size holding the items in [top, bottom)
+func (a *circularArray) grow(top, bottom int64) *circularArray {
+	bigger := newCircularArray(2 * a.size())
+	for i := top; i < bottom; i++ {
+		bigger.put(i, a.get(i))
+	}
+	return bigger
+}
+
+// O(1); may be momentarily stale while other goroutines are pushing or stealing
+func (q *ChaseLevDEQueue) Size() int {
+	size := atomic.LoadInt64(&q.bottom) - atomic.LoadInt64(&q.top)
+	if size < 0 {
+		return 0 // the owner is in the middle of popping the last task
+	}
+	return int(size)
+}
+
+func (q *ChaseLevDEQueue) IsEmpty() bool {
+	return q.Size() == 0
+}
+
+// owner only; pushes a task onto the bottom, growing the array if it's full
+func (q *ChaseLevDEQueue) PushBottom(task Task) {
+	bottom := atomic.LoadInt64(&q.bottom)
+	top := atomic.LoadInt64(&q.top)
+	a := (*circularArray)(atomic.LoadPointer(&q.array))
+	if bottom-top > a.size()-1 {
+		a = a.grow(top, bottom)
+		atomic.StorePointer(&q.array, unsafe.Pointer(a))
+	}
+	a.put(bottom, task)
+	atomic.StoreInt64(&q.bottom, bottom+1) // publishes the task to thieves
+}
+
+// owner only; pops the most recently pushed task
+func (q *ChaseLevDEQueue) PopBottom() Task {
+	bottom := atomic.LoadInt64(&q.bottom) - 1
+	a := (*circularArray)(atomic.LoadPointer(&q.array))
+	atomic.StoreInt64(&q.bottom, bottom) // reserve the bottom slot before looking at top
+	top := atomic.LoadInt64(&q.top)
+
+	if top > bottom { // deque was empty
+		atomic.StoreInt64(&q.bottom, bottom+1)
+		return nil
+	}
+
+	task := a.get(bottom)
+	if top == bottom {
+		// this is the last task, so we race the thieves for it
+		if !atomic.CompareAndSwapInt64(&q.top, top, top+1) {
+			task = nil // a thief got it first
+		}
+		atomic.StoreInt64(&q.bottom, bottom+1)
+	}
+	return task
+}
+
+// any goroutine; steals the oldest task
+func (q *ChaseLevDEQueue) PopTop() Task {
+	top := atomic.LoadInt64(&q.top)
+	bottom := atomic.LoadInt64(&q.bottom)
+	if top >= bottom {
+		return nil // empty
+	}
+
+	a := (*circularArray)(atomic.LoadPointer(&q.array))
+	task := a.get(top)
+	if !atomic.CompareAndSwapInt64(&q.top, top, top+1) {
+		return nil // lost the race to another thief or the owner
+	}
+	return task
+}
@@ -0,0 +1,148 @@
+package concurrent
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestChaseLevOwnerOnly(t *testing.T) {
+	q := NewChaseLevDEQueue()
+	if q.PopBottom() != nil || q.PopTop() != nil || !q.IsEmpty() {
+		t.Fatal("new deque isn't empty")
+	}
+	const n = 3*chaseLevInitialSize + 5 // grows twice
+	for i := 0; i < n; i++ {
+		q.PushBottom(i)
+	}
+	if q.Size() != n {
+		t.Fatalf("size %d after %d pushes", q.Size(), n)
+	}
+	if task := q.PopTop(); task != 0 {
+		t.Fatalf("PopTop = %v, want the oldest task 0", task)
+	}
+	for i := n - 1; i > 0; i-- {
+		if task := q.PopBottom(); task != i {
+			t.Fatalf("PopBottom = %v, want %d", task, i)
+		}
+	}
+	if q.PopBottom() != nil || !q.IsEmpty() {
+		t.Fatal("deque isn't empty after popping everything")
+	}
+}
+
+// a slot that was never written reads as nil, which is what a thief holding a stale top sees
+// after the owner grew the array past it
+func TestCircularArrayEmptySlot(t *testing.T) {
+	a := newCircularArray(4)
+	a.put(1, 7)
+	bigger := a.grow(1, 2)
+	if task := bigger.get(0); task != nil {
+		t.Fatalf("empty slot holds %v", task)
+	}
+	if task := bigger.get(1); task != 7 {
+		t.Fatalf("grown slot holds %v, want 7", task)
+	}
+}
+
+// the owner pushes bursts of tasks (growing the array) and pops some of them back
+// while thieves steal from the top; every task must come out exactly once
+func TestChaseLevStress(t *testing.T) {
+	for _, thieves := range []int{1, 3, 7} {
+		const tasks = 200000
+		q := NewChaseLevDEQueue()
+		counts := make([]int32, tasks)
+		var taken int64
+		take := func(task Task) {
+			atomic.AddInt32(&counts[task.(int)], 1)
+			atomic.AddInt64(&taken, 1)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < thieves; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for atomic.LoadInt64(&taken) < tasks {
+					if task := q.PopTop(); task != nil {
+						take(task)
+					} else {
+						runtime.Gosched()
+					}
+				}
+			}()
+		}
+
+		next := 0
+		for burst := 1; next < tasks; burst = burst%300 + 1 {
+			for i := 0; i < burst && next < tasks; i++ {
+				q.PushBottom(next)
+				next++
+			}
+			for i := 0; i < burst/3; i++ {
+				if task := q.PopBottom(); task != nil {
+					take(task)
+				}
+			}
+		}
+		for atomic.LoadInt64(&taken) < tasks { // help drain whatever the thieves haven't taken
+			if task := q.PopBottom(); task != nil {
+				take(task)
+			}
+		}
+		wg.Wait()
+
+		for task, count := range counts {
+			if count != 1 {
+				t.Fatalf("%d thieves: task %d delivered %d times", thieves, task, count)
+			}
+		}
+		if !q.IsEmpty() {
+			t.Fatalf("%d thieves: %d tasks left over", thieves, q.Size())
+		}
+	}
+}
+
+// the owner pushes tasks and pops half of them back while 3 thieves steal the rest
+// the time is per task
+func benchmarkDEQueue(b *testing.B, newDEQueue func() DEQueue) {
+	q := newDEQueue()
+	var taken int64
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&taken) < int64(b.N) {
+				if q.PopTop() != nil {
+					atomic.AddInt64(&taken, 1)
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.PushBottom(i)
+		if i%2 == 1 && q.PopBottom() != nil {
+			atomic.AddInt64(&taken, 1)
+		}
+	}
+	for atomic.LoadInt64(&taken) < int64(b.N) {
+		if q.PopBottom() != nil {
+			atomic.AddInt64(&taken, 1)
+		}
+	}
+	wg.Wait()
+}
+
+func BenchmarkChaseLev(b *testing.B) {
+	benchmarkDEQueue(b, NewChaseLevDEQueue)
+}
+
+func BenchmarkUnbounded(b *testing.B) {
+	benchmarkDEQueue(b, NewUnBoundedDEQueue)
+}
@@ -0,0 +1,91 @@
+package concurrent
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// a CPU-bound task that touches no shared memory
+type spinTask int
+
+func (task spinTask) Call() interface{} {
+	x := float64(task)
+	for i := 0; i < 20000; i++ {
+		x = math.Sqrt(x*x + 1)
+	}
+	return x
+}
+
+func TestExecutorsRunEveryTask(t *testing.T) {
+	executors := map[string]func() ExecutorService{
+		"ws":          func() ExecutorService { return NewWorkStealingExecutor(4, 10) },
+		"ws-chaselev": func() ExecutorService { return NewWorkStealingExecutor(4, 10, WithDEQueue(NewChaseLevDEQueue)) },
+		"wb":          func() ExecutorService { return NewWorkBalancingExecutor(4, 10, 10) },
+	}
+	for name, newExecutor := range executors {
+		t.Run(name, func(t *testing.T) {
+			executor := newExecutor()
+			futures := make([]Future, 500)
+			for i := range futures {
+				futures[i] = executor.Submit(spinTask(i))
+			}
+			for i, future := range futures {
+				if want := spinTask(i).Call(); future.Get() != want {
+					t.Fatalf("task %d: got %v, want %v", i, future.Get(), want)
+				}
+			}
+			executor.Shutdown()
+			if executor.Submit(spinTask(0)) != nil {
+				t.Error("Submit after Shutdown returned a future")
+			}
+		})
+	}
+}
+
+// every op submits 256 CPU-bound tasks and waits for all of them
+// the speedup metric is the executor's time per op on 1 thread over its time per op on this many threads;
+// with no shared state between tasks it should be close to the thread count, up to the number of cores (GOMAXPROCS)
+func BenchmarkExecutorSpeedup(b *testing.B) {
+	executors := []struct {
+		name string
+		new  func(threads int) ExecutorService
+	}{
+		{"ws", func(threads int) ExecutorService { return NewWorkStealingExecutor(threads, 10) }},
+		{"wb", func(threads int) ExecutorService { return NewWorkBalancingExecutor(threads, 10, 10) }},
+	}
+	threadCounts := []int{1, 2, 4, 8}
+	if cores := runtime.GOMAXPROCS(0); cores > 8 {
+		threadCounts = append(threadCounts, cores)
+	}
+	for _, executor := range executors {
+		var single float64 // ns per op on 1 thread
+		for _, threads := range threadCounts {
+			b.Run(fmt.Sprintf("%s/threads=%d", executor.name, threads), func(b *testing.B) {
+				service := executor.new(threads)
+				futures := make([]Future, 256)
+				b.ResetTimer()
+				start := time.Now()
+				for n := 0; n < b.N; n++ {
+					for i := range futures {
+						futures[i] = service.Submit(spinTask(i))
+					}
+					for _, future := range futures {
+						future.Get()
+					}
+				}
+				b.StopTimer()
+				perOp := float64(time.Since(start).Nanoseconds()) / float64(b.N)
+				if threads == 1 {
+					single = perOp
+				}
+				if single > 0 { // -bench may have filtered out the 1-thread run
+					b.ReportMetric(single/perOp, "speedup")
+				}
+				service.Shutdown()
+			})
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package concurrent
+
+// the future handed back by Submit
+// it is completed by whichever goroutine ends up running the task
+type taskFuture struct {
+	done   chan struct{}
+	result interface{}
+}
+
+// blocks until the task has finished running
+// returns the value from Call for a Callable, nil for a Runnable
+func (future *taskFuture) Get() interface{} {
+	<-future.done
+	return future.result
+}
+
+// a submitted task paired with its future; this is what actually sits on the deques
+type futureTask struct {
+	task   interface{}
+	future *taskFuture
+}
+
+// wraps a Runnable or Callable so that running it completes a future
+func newFutureTask(task interface{}) *futureTask {
+	switch task.(type) {
+	case Callable, Runnable:
+	default:
+		panic("Submitted task must be a Runnable or a Callable.")
+	}
+	return &futureTask{
+		task:   task,
+		future: &taskFuture{done: make(chan struct{})},
+	}
+}
+
+// runs the wrapped task and completes the future
+// Callable is checked first so a task implementing both still yields its value
+func (ft *futureTask) Run() {
+	switch task := ft.task.(type) {
+	case Callable:
+		ft.future.result = task.Call()
+	case Runnable:
+		task.Run()
+	}
+	close(ft.future.done) // wakes up everyone blocked in Get
+}
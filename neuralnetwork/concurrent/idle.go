@@ -0,0 +1,159 @@
+package concurrent
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IdleStrategy controls what a worker does when it looks for work and finds none.
+// It first re-polls straight away Spins times, then sleeps with exponential backoff
+// starting at MinBackoff and doubling up to MaxBackoff, then (if Park is set) blocks
+// until Submit, Shutdown, or another worker signals that there may be work.
+// Without Park a worker keeps sleeping MaxBackoff between polls; with neither
+// backoff nor Park it spins forever, which was the original behavior.
+// A worker that wants to park while other workers still have tasks queued sleeps
+// MaxBackoff (or 100µs without backoff) instead and then polls again.
+type IdleStrategy struct {
+	Spins      int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Park       bool
+}
+
+var (
+	// lowest latency, but every idle worker burns a core
+	SpinIdleStrategy = IdleStrategy{}
+	// spins briefly then sleeps; never blocks
+	BackoffIdleStrategy = IdleStrategy{Spins: 100, MinBackoff: 10 * time.Microsecond, MaxBackoff: time.Millisecond}
+	// blocks as soon as there is nothing to do
+	ParkIdleStrategy = IdleStrategy{Park: true}
+	// spins, backs off, then blocks; used unless WithIdleStrategy says otherwise
+	DefaultIdleStrategy = IdleStrategy{Spins: 100, MinBackoff: 10 * time.Microsecond, MaxBackoff: time.Millisecond, Park: true}
+)
+
+// the names FindIdleStrategy accepts, e.g. for a -idle flag
+var IdleStrategyNames = []string{"default", "spin", "backoff", "park"}
+
+// returns the predefined strategy with the given name; "" means default
+func FindIdleStrategy(name string) (IdleStrategy, error) {
+	switch name {
+	case "", "default":
+		return DefaultIdleStrategy, nil
+	case "spin":
+		return SpinIdleStrategy, nil
+	case "backoff":
+		return BackoffIdleStrategy, nil
+	case "park":
+		return ParkIdleStrategy, nil
+	}
+	return IdleStrategy{}, fmt.Errorf("unknown idle strategy %q (want one of %v)", name, IdleStrategyNames)
+}
+
+// shared by all of an executor's workers
+// epoch is bumped every time there may be new work, so a worker that read the epoch
+// before looking for work knows not to park if anything changed since (no lost wake-ups)
+type idler struct {
+	strategy IdleStrategy
+	epoch    uint64 // updated atomically
+	parked   int32  // number of workers blocked in park; updated atomically
+	mutex    *sync.Mutex
+	cond     *sync.Cond
+}
+
+// per-worker progress through the spin/backoff/park phases
+type idleState struct {
+	polls int
+	sleep time.Duration
+}
+
+func newIdler(strategy IdleStrategy) *idler {
+	mutex := &sync.Mutex{}
+	return &idler{
+		strategy: strategy,
+		mutex:    mutex,
+		cond:     sync.NewCond(mutex),
+	}
+}
+
+// read this before looking for work and pass it to wait
+func (idler *idler) Epoch() uint64 {
+	return atomic.LoadUint64(&idler.epoch)
+}
+
+// tells idle workers there may be new work (or that it's time to exit)
+// only takes the lock if somebody is actually parked
+func (idler *idler) wake() {
+	atomic.AddUint64(&idler.epoch, 1)
+	if atomic.LoadInt32(&idler.parked) > 0 {
+		idler.mutex.Lock()
+		idler.cond.Broadcast()
+		idler.mutex.Unlock()
+	}
+}
+
+// called after a worker found nothing to do
+// epoch is the value of Epoch() from before the worker looked for work
+// canPark reports whether there is really nothing the worker could pick up; it is only checked before parking
+func (idler *idler) wait(state *idleState, epoch uint64, canPark func() bool) {
+	strategy := idler.strategy
+	state.polls++
+	if state.polls <= strategy.Spins {
+		return // spin: poll again straight away
+	}
+
+	if strategy.MaxBackoff > 0 && (state.sleep < strategy.MaxBackoff || !strategy.Park) {
+		if state.sleep == 0 {
+			state.sleep = strategy.MinBackoff
+			if state.sleep <= 0 {
+				state.sleep = strategy.MaxBackoff
+			}
+		}
+		time.Sleep(state.sleep)
+		state.sleep *= 2
+		if state.sleep > strategy.MaxBackoff {
+			state.sleep = strategy.MaxBackoff
+		}
+		return
+	}
+
+	if !strategy.Park {
+		return // spin
+	}
+	if canPark() {
+		idler.park(epoch)
+		state.reset()
+		return
+	}
+
+	// there is work queued somewhere that we failed to take, so we can't park (we might never be woken)
+	// but polling again straight away would spin; wait as long as the longest backoff instead
+	if strategy.MaxBackoff > 0 {
+		time.Sleep(strategy.MaxBackoff)
+	} else {
+		time.Sleep(refusedParkSleep)
+	}
+}
+
+// how long a worker whose strategy has no backoff sleeps when it can't park
+const refusedParkSleep = 100 * time.Microsecond
+
+// blocks until the epoch moves past the one the worker saw
+// parked is incremented before re-reading the epoch and wake bumps the epoch before reading parked,
+// so either we see the new epoch or wake sees us and broadcasts
+func (idler *idler) park(epoch uint64) {
+	idler.mutex.Lock()
+	atomic.AddInt32(&idler.parked, 1)
+	for atomic.LoadUint64(&idler.epoch) == epoch {
+		idler.cond.Wait()
+	}
+	atomic.AddInt32(&idler.parked, -1)
+	idler.mutex.Unlock()
+}
+
+// called once the worker finds work again
+func (state *idleState) reset() {
+	state.polls = 0
+	state.sleep = 0
+}
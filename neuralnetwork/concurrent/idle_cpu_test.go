@@ -0,0 +1,66 @@
+//go:build !windows
+// +build !windows
+
+package concurrent
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// the user plus system CPU time the process has used so far
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		panic(err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// a task that records how long after its Submit it started running
+type latencyTask struct {
+	submitted time.Time
+}
+
+func (task *latencyTask) Call() interface{} {
+	return time.Since(task.submitted)
+}
+
+// every op leaves the executor idle for a gap, then submits one task and waits for it
+// reports the mean time from Submit until the task started (latency-µs) and the CPU time the process
+// burned per second of wall time (cpu-s/s): about the worker count for spin, close to 0 for park
+func BenchmarkIdleStrategy(b *testing.B) {
+	const workers = 4
+	const gap = 5 * time.Millisecond // long enough for the default strategy to get through to parking
+	executors := []struct {
+		name string
+		new  func(opts ...ExecutorOption) ExecutorService
+	}{
+		{"ws", func(opts ...ExecutorOption) ExecutorService { return NewWorkStealingExecutor(workers, 10, opts...) }},
+		{"wb", func(opts ...ExecutorOption) ExecutorService {
+			return NewWorkBalancingExecutor(workers, 10, 10, opts...)
+		}},
+	}
+	for _, executor := range executors {
+		for _, name := range IdleStrategyNames {
+			strategy, _ := FindIdleStrategy(name)
+			b.Run(fmt.Sprintf("%s/%s", executor.name, name), func(b *testing.B) {
+				service := executor.new(WithIdleStrategy(strategy))
+				var latency time.Duration
+				b.ResetTimer()
+				start, startCPU := time.Now(), cpuTime()
+				for n := 0; n < b.N; n++ {
+					time.Sleep(gap)
+					latency += service.Submit(&latencyTask{submitted: time.Now()}).Get().(time.Duration)
+				}
+				cpu, wall := cpuTime()-startCPU, time.Since(start)
+				b.StopTimer()
+				b.ReportMetric(float64(latency.Microseconds())/float64(b.N), "latency-µs")
+				b.ReportMetric(cpu.Seconds()/wall.Seconds(), "cpu-s/s")
+				service.Shutdown()
+			})
+		}
+	}
+}
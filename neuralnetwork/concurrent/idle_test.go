@@ -0,0 +1,64 @@
+package concurrent
+
+import (
+	"testing"
+	"time"
+)
+
+// once the backoff has reached MaxBackoff a worker that isn't allowed to park must still sleep,
+// otherwise it polls in a hot loop for as long as some other worker has tasks queued
+func TestRefusedParkSleeps(t *testing.T) {
+	for name, strategy := range map[string]IdleStrategy{"default": DefaultIdleStrategy, "park": ParkIdleStrategy} {
+		t.Run(name, func(t *testing.T) {
+			idler := newIdler(strategy)
+			state := idleState{}
+			cannotPark := func() bool { return false }
+			for i := 0; i < strategy.Spins+20; i++ { // through the spins and up to the longest backoff
+				idler.wait(&state, idler.Epoch(), cannotPark)
+			}
+
+			want := strategy.MaxBackoff
+			if want == 0 {
+				want = refusedParkSleep
+			}
+			const polls = 10
+			start := time.Now()
+			for i := 0; i < polls; i++ {
+				idler.wait(&state, idler.Epoch(), cannotPark)
+			}
+			if elapsed := time.Since(start); elapsed < polls*want {
+				t.Errorf("%d refused parks took %v, want at least %v", polls, elapsed, polls*want)
+			}
+		})
+	}
+}
+
+func TestParkWakesOnNewEpoch(t *testing.T) {
+	idler := newIdler(ParkIdleStrategy)
+	state := idleState{}
+	epoch := idler.Epoch()
+	woken := make(chan struct{})
+	go func() {
+		idler.wait(&state, epoch, func() bool { return true })
+		close(woken)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	idler.wake()
+	select {
+	case <-woken:
+	case <-time.After(5 * time.Second):
+		t.Fatal("parked worker wasn't woken")
+	}
+}
+
+func TestSpinNeverSleeps(t *testing.T) {
+	idler := newIdler(SpinIdleStrategy)
+	state := idleState{}
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		idler.wait(&state, idler.Epoch(), func() bool { return false })
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("1000 spins took %v", elapsed)
+	}
+}
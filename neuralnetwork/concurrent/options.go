@@ -0,0 +1,37 @@
+package concurrent
+
+// ExecutorOption customizes an executor when it is constructed
+type ExecutorOption func(*executorOptions)
+
+type executorOptions struct {
+	newDEQueue func() DEQueue // constructor for each worker's local deque
+	idle       IdleStrategy
+}
+
+func newExecutorOptions(opts []ExecutorOption) executorOptions {
+	options := executorOptions{
+		newDEQueue: NewUnBoundedDEQueue,
+		idle:       DefaultIdleStrategy,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithDEQueue sets the constructor used for each worker's local deque, e.g. NewChaseLevDEQueue.
+// The work-stealing executor only calls PushBottom and PopBottom on a deque from the worker that owns it,
+// so single-owner deques are safe to use. Defaults to NewUnBoundedDEQueue.
+// The work-balancing executor moves tasks between deques and ignores this option.
+func WithDEQueue(newDEQueue func() DEQueue) ExecutorOption {
+	return func(options *executorOptions) {
+		options.newDEQueue = newDEQueue
+	}
+}
+
+// WithIdleStrategy sets what workers do while they have nothing to run. Defaults to DefaultIdleStrategy.
+func WithIdleStrategy(strategy IdleStrategy) ExecutorOption {
+	return func(options *executorOptions) {
+		options.idle = strategy
+	}
+}
@@ -3,16 +3,20 @@ package concurrent
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 )
 
 type WorkStealingExecutor struct {
-	capacity int
-	tasks    int
-	index    int
-	shutdown bool
-	wg       *sync.WaitGroup
-	lock     *sync.Mutex
-	deques   []DEQueue
+	capacity  int
+	threshold int
+	tasks     int64 // submitted tasks that haven't finished running; updated atomically
+	index     int
+	shutdown  int32 // set to 1 (atomically) by Shutdown
+	wg        *sync.WaitGroup
+	lock      *sync.Mutex // only serializes Submit and Shutdown; workers never take it
+	inboxes   []DEQueue   // where Submit puts tasks; safe for any goroutine to push to or steal from
+	deques    []DEQueue   // each worker's local deque; only its owner pushes or pops the bottom
+	idler     *idler
 }
 
 // NewWorkStealingExecutor returns an ExecutorService that is implemented using the work-stealing algorithm.
@@ -22,21 +26,33 @@ type WorkStealingExecutor struct {
 // this means that a goroutine can grab 10 items from the executor all at
 // once to place into their local queue before grabbing more items. It's
 // not required that you use this parameter in your implementation.
-func NewWorkStealingExecutor(capacity, threshold int) ExecutorService {
-	// create an array of deques - one for each thread
+// @param opts - optional settings, e.g. WithDEQueue(NewChaseLevDEQueue) for lock-free local deques
+// or WithIdleStrategy to trade CPU time for latency while workers wait for tasks
+func NewWorkStealingExecutor(capacity, threshold int, opts ...ExecutorOption) ExecutorService {
+	options := newExecutorOptions(opts)
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	// create an inbox and a local deque for each thread
+	inboxes := make([]DEQueue, capacity)
 	deque := make([]DEQueue, capacity)
 	for i := 0; i < capacity; i++ {
-		deque[i] = NewUnBoundedDEQueue()
+		inboxes[i] = NewUnBoundedDEQueue()
+		deque[i] = options.newDEQueue()
 	}
 
 	// create the executor
 	executor := &WorkStealingExecutor{
-		capacity: capacity,
-		tasks:    0,
-		index:    0,
-		wg:       &sync.WaitGroup{},
-		lock:     &sync.Mutex{},
-		deques:   deque,
+		capacity:  capacity,
+		threshold: threshold,
+		tasks:     0,
+		index:     0,
+		wg:        &sync.WaitGroup{},
+		lock:      &sync.Mutex{},
+		inboxes:   inboxes,
+		deques:    deque,
+		idler:     newIdler(options.idle),
 	}
 
 	executor.BeginExecutor() // launch the threads
@@ -52,57 +68,118 @@ func (executor *WorkStealingExecutor) BeginExecutor() {
 	}
 }
 
+// accepts a Runnable or a Callable and returns a Future for it
+// tasks are handed out to the workers' inboxes round-robin
 func (executor *WorkStealingExecutor) Submit(task interface{}) Future {
 	executor.lock.Lock()
 	defer executor.lock.Unlock()
+	if atomic.LoadInt32(&executor.shutdown) == 1 {
+		return nil // submits after Shutdown are ignored
+	}
+	ft := newFutureTask(task)
 	executor.index = executor.index % executor.capacity
-	executor.deques[executor.index].PushBottom(task) // add task to the end of the deque
-	executor.tasks++
+	atomic.AddInt64(&executor.tasks, 1)             // count the task before any worker can see it
+	executor.inboxes[executor.index].PushBottom(ft) // add task to the end of the inbox
 	executor.index++
-	return nil // nil future
+	executor.idler.wake()
+	return ft.future
 }
 
+// all tasks are submitted before Shutdown, so once a worker sees the shutdown flag
+// the task count can only go down; workers exit when it reaches 0
 func (executor *WorkStealingExecutor) Shutdown() {
 	executor.lock.Lock()
-	executor.shutdown = true
+	atomic.StoreInt32(&executor.shutdown, 1)
 	executor.lock.Unlock()
-	executor.wg.Wait() // wait until all goroutines are done
+	executor.idler.wake() // parked workers need to recheck whether they're done
+	executor.wg.Wait()    // wait until all goroutines are done
+}
+
+// true once Shutdown has been called and every submitted task has finished
+func (executor *WorkStealingExecutor) done() bool {
+	return atomic.LoadInt32(&executor.shutdown) == 1 && atomic.LoadInt64(&executor.tasks) == 0
+}
+
+// moves up to threshold tasks from a worker's inbox onto its local deque
+// only the owning worker calls this, so only the owner ever pushes onto its local deque
+// returns false if the inbox was empty
+func (executor *WorkStealingExecutor) refill(threadId int) bool {
+	moved := 0
+	for moved < executor.threshold {
+		task := executor.inboxes[threadId].PopTop()
+		if task == nil {
+			break
+		}
+		executor.deques[threadId].PushBottom(task)
+		moved++
+	}
+	if moved > 1 {
+		executor.idler.wake() // there is something on our deque for idle workers to steal
+	}
+	return moved > 0
+}
+
+// true if there is nothing queued anywhere, i.e. an idle worker may as well park
+func (executor *WorkStealingExecutor) nothingToSteal() bool {
+	for i := 0; i < executor.capacity; i++ {
+		if !executor.inboxes[i].IsEmpty() || !executor.deques[i].IsEmpty() {
+			return false
+		}
+	}
+	return true
 }
 
 // run tasks; threadId refers to the local deque the thread will be operating on
+// the owner takes work from the bottom of its deque, thieves take from the top
 func (executor *WorkStealingExecutor) StealingWorker(threadId int) {
 	defer executor.wg.Done()
+	idle := idleState{}
 	// there will be a gap between when this loop starts iterating and tasks are submitted
 	// our "shutdown" variable prevents this loop from terminating before all tasks have been submitted
 	for {
-		if executor.deques[threadId].IsEmpty() {
-			if executor.shutdown && executor.tasks == 0 {
+		epoch := executor.idler.Epoch() // read before looking so a Submit in the meantime stops us parking
+		task := executor.deques[threadId].PopBottom()
+		if task == nil {
+			if executor.refill(threadId) {
+				continue // grabbed more work from our inbox
+			}
+			if executor.done() {
 				return // no more work to do
 			}
 
-			// pick a random deque
-			randDequeId := threadId
-			for randDequeId == threadId { // make sure the random deque is not the same as the current thread
-				randDequeId = rand.Intn(executor.capacity)
+			// PopTop returns nil if the victim is empty or another thread beat us to it
+			task = executor.steal(threadId)
+			if task == nil {
+				executor.idler.wait(&idle, epoch, executor.nothingToSteal)
+				continue
 			}
+		}
 
-			// lock here to prevent the random deque from becoming empty before we poptop
-			executor.lock.Lock()
-			if !executor.deques[randDequeId].IsEmpty() { // if not empty, then steal
-				task, _ := executor.deques[randDequeId].PopTop().(Runnable) // https://go.dev/tour/methods/15
-				task.Run()
-				executor.tasks--
-			}
-			executor.lock.Unlock()
-
-		} else {
-			// there is work to do
-			task, _ := executor.deques[threadId].PopTop().(Runnable)
-			executor.lock.Lock()
-			task.Run()
-			executor.tasks--
-			executor.lock.Unlock()
+		// there is work to do; run it without holding any shared lock
+		idle.reset()
+		runnable, _ := task.(Runnable) // https://go.dev/tour/methods/15
+		runnable.Run()
+		if atomic.AddInt64(&executor.tasks, -1) == 0 {
+			executor.idler.wake() // parked workers may be able to exit now
 		}
+	}
+}
 
+// tries to take a task off the top of a random other worker's deque, or failing that its inbox
+// inboxes have to be stealable too: a busy worker only refills threshold tasks at a time,
+// so when more than that were submitted to it the rest wait in its inbox
+func (executor *WorkStealingExecutor) steal(threadId int) Task {
+	if executor.capacity == 1 {
+		return nil // nobody to steal from
+	}
+
+	// pick a random victim
+	randDequeId := threadId
+	for randDequeId == threadId { // make sure the random deque is not the same as the current thread
+		randDequeId = rand.Intn(executor.capacity)
+	}
+	if task := executor.deques[randDequeId].PopTop(); task != nil {
+		return task
 	}
+	return executor.inboxes[randDequeId].PopTop() // the inbox is a locked deque, safe for any goroutine
 }
@@ -0,0 +1,45 @@
+package concurrent
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type runFunc func()
+
+func (f runFunc) Run() { f() }
+
+// worker 0 is stuck on a task that only finishes once every other task has run,
+// so the tasks waiting in its inbox have to be stolen by worker 1
+func TestStealingFromInboxes(t *testing.T) {
+	for name, newDEQueue := range map[string]func() DEQueue{"unbounded": NewUnBoundedDEQueue, "chaselev": NewChaseLevDEQueue} {
+		t.Run(name, func(t *testing.T) {
+			executor := NewWorkStealingExecutor(2, 1, WithDEQueue(newDEQueue))
+			const others = 20
+			var rest sync.WaitGroup
+			rest.Add(others)
+			stolen := make(chan bool, 1)
+			executor.Submit(runFunc(func() {
+				done := make(chan struct{})
+				go func() {
+					rest.Wait()
+					close(done)
+				}()
+				select {
+				case <-done:
+					stolen <- true
+				case <-time.After(5 * time.Second):
+					stolen <- false
+				}
+			}))
+			for i := 0; i < others; i++ { // round robin: every other task lands in worker 0's inbox
+				executor.Submit(runFunc(rest.Done))
+			}
+			if !<-stolen {
+				t.Fatal("tasks in a busy worker's inbox were never stolen")
+			}
+			executor.Shutdown()
+		})
+	}
+}
@@ -75,10 +75,13 @@ func (q *UnBoundedDEQueue) IsEmpty() bool {
 	return q.front == nil // if front is nil, queue is empty
 }
 
-// pops the top task off
+// pops the top task off; returns nil if the list is empty
 func (q *UnBoundedDEQueue) PopTop() Task {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
+	if q.front == nil {
+		return nil
+	}
 	task := q.front.task
 	q.front = q.front.back // move front pointer to the node behind the current front node
 	if q.front == nil {
@@ -89,10 +92,13 @@ func (q *UnBoundedDEQueue) PopTop() Task {
 	return task
 }
 
-// pops the bottom task off
+// pops the bottom task off; returns nil if the list is empty
 func (q *UnBoundedDEQueue) PopBottom() Task {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
+	if q.back == nil {
+		return nil
+	}
 	task := q.back.task
 	q.back = q.back.front // move back pointer to the node ahead of the current back node
 	if q.back == nil {
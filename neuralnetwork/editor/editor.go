@@ -1,37 +1,135 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"proj3/scheduler"
 	"strconv"
+	"strings"
 	"time"
 )
 
-const usage = "Usage: editor mode [number of threads]\n" +
-	"mode     = (bsp) run the BSP mode, (pipeline) run the pipeline mode\n" +
-	"number of epochs\n" +
-	"[number of threads] = Runs the parallel version of the program with the specified number of threads.\n"
+const usage = "Usage: editor [flags] epochs mode [number of threads]\n" +
+	"epochs   = number of epochs\n" +
+	"mode     = (s) run sequentially, (ws) run with work stealing, (wb) run with work balancing,\n" +
+	"           (dp) run synchronous data parallel training with gradient all-reduce\n" +
+	"[number of threads] = Runs the parallel version of the program with the specified number of threads.\n" +
+	"flags:\n" +
+	"  -idle s        what idle workers do: spin (lowest latency, burns a core each), backoff (spin, then sleep),\n" +
+	"                 park (block right away) or default (spin, sleep, then block) (default default)\n" +
+	"  -dataset s     mnist, fashion, kmnist, emnist-letters or emnist-balanced (default mnist)\n" +
+	"  -layers sizes  comma separated layer sizes, input first; the first and last must be the dataset's\n" +
+	"                 inputs and classes (default inputs,10,classes, e.g. 784,10,10 for mnist)\n" +
+	"  -activation s  hidden layer activation: relu, leakyrelu, elu, gelu, sigmoid, tanh or softmax, or a comma\n" +
+	"                 separated list with one per hidden layer; leakyrelu:a and elu:a set alpha (default relu)\n" +
+	"  -data dir      directory with the dataset's files (default $MNIST_DIR, or ../../proj3/mnist if that isn't set)\n" +
+	"  -load path     start from a saved model instead of a random one (0 epochs only evaluates it)\n" +
+	"  -save path     save the trained model\n" +
+	"  -json path     also write the evaluation report as JSON\n" +
+	"  -batch n       mini-batch size; 0 trains on the full batch (default 0)\n" +
+	"  -seed n        seed for the initial weights and the mini-batch order; the same seed reproduces a run exactly\n" +
+	"                 0 picks one from the clock and the report shows it (default 0)\n" +
+	"  -init s        weight initialization: uniform (in [-0.5, 0.5)), xavier, he or lecun (default uniform)\n" +
+	"  -optimizer s   sgd, momentum, nesterov, rmsprop, adam or adamw (default sgd)\n" +
+	"  -lr x          learning rate (default 0.1)\n" +
+	"  -sync k        ws/wb: average the ensemble every k epochs from a shared start (default 0: once, at the end)\n" +
+	"  -combine s     ws/wb: how the ensemble predicts: weights (average the weights), mean, geomean or vote\n" +
+	"                 (average the members' softmax outputs, their logs, or take a majority vote) (default weights)\n" +
+	"  -compare       ws/wb: also report test accuracy for every -combine method\n" +
+	"  -chunks n      ws/wb: split the training set into n chunks of (nearly) equal size\n" +
+	"  -chunksize n   ws/wb: samples per chunk; the final chunk gets the remainder (default 1000)\n" +
+	"                 with both -chunks and -chunksize, only the first n chunks of that size are used\n" +
+	"  -stratify      ws/wb: give every chunk the same class balance as the whole training set\n" +
+	"  -gemm n        split every large matrix multiply across n goroutines (default 1)\n" +
+	"The evaluation report on the test set is printed first, followed by the elapsed seconds.\n"
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println(usage)
+	flag.Usage = func() { fmt.Print(usage) }
+	idle := flag.String("idle", "default", "")
+	layers := flag.String("layers", "", "")
+	activations := flag.String("activation", "", "")
+	dataset := flag.String("dataset", "mnist", "")
+	dataDir := flag.String("data", "", "")
+	load := flag.String("load", "", "")
+	save := flag.String("save", "", "")
+	jsonPath := flag.String("json", "", "")
+	batchSize := flag.Int("batch", 0, "")
+	seed := flag.Int64("seed", 0, "")
+	initializer := flag.String("init", "uniform", "")
+	optimizer := flag.String("optimizer", "sgd", "")
+	learningRate := flag.Float64("lr", 0.1, "")
+	syncEvery := flag.Int("sync", 0, "")
+	combine := flag.String("combine", "weights", "")
+	compare := flag.Bool("compare", false, "")
+	chunks := flag.Int("chunks", 0, "")
+	chunkSize := flag.Int("chunksize", 0, "")
+	stratify := flag.Bool("stratify", false, "")
+	gemmThreads := flag.Int("gemm", 1, "")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 2 {
+		fmt.Print(usage)
 		return
 	}
 
-	config := scheduler.Config{Mode: "", Epochs: 0, ThreadCount: 0}
-	if len(os.Args) >= 4 {
-		config.Epochs, _ = strconv.Atoi(os.Args[1])
-		config.Mode = os.Args[2]
-		config.ThreadCount, _ = strconv.Atoi(os.Args[3])
+	config := scheduler.Config{Mode: "", Epochs: 0, ThreadCount: 0, Dataset: *dataset, DataDir: *dataDir, Load: *load, Save: *save, BatchSize: *batchSize, Seed: *seed,
+		Init: *initializer, Optimizer: *optimizer, LearningRate: *learningRate, SyncEvery: *syncEvery,
+		Combine: *combine, Compare: *compare, Chunks: *chunks, ChunkSize: *chunkSize, Stratify: *stratify,
+		GEMMThreads: *gemmThreads, Idle: *idle}
+	if len(args) >= 3 {
+		config.Epochs, _ = strconv.Atoi(args[0])
+		config.Mode = args[1]
+		config.ThreadCount, _ = strconv.Atoi(args[2])
 	} else {
 		config.Mode = "s"
-		config.Epochs, _ = strconv.Atoi(os.Args[1])
+		config.Epochs, _ = strconv.Atoi(args[0])
+	}
+
+	if *layers != "" {
+		sizes, err := parseLayers(*layers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Print(usage)
+			return
+		}
+		config.Layers = sizes
+	}
+
+	if *activations != "" {
+		for _, name := range strings.Split(*activations, ",") {
+			config.Activations = append(config.Activations, strings.TrimSpace(name))
+		}
 	}
 
 	start := time.Now()
-	scheduler.Schedule(config)
+	report, err := scheduler.Schedule(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
 	end := time.Since(start).Seconds()
-	fmt.Printf("%.2f\n", end)
 
+	report.WriteTable(os.Stdout)
+	if *jsonPath != "" {
+		if err := report.SaveJSON(*jsonPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	fmt.Printf("%.2f\n", end) // last line, so benchmark scripts can pick out the time
+
+}
+
+// parses a comma separated list of layer sizes, e.g. "784,128,64,10"
+func parseLayers(s string) ([]int, error) {
+	fields := strings.Split(s, ",")
+	sizes := make([]int, len(fields))
+	for i, field := range fields {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid layer size %q", field)
+		}
+		sizes[i] = size
+	}
+	return sizes, nil
 }
@@ -1,3 +1,3 @@
 module proj3
 
-go 1.16
\ No newline at end of file
+go 1.18
\ No newline at end of file
@@ -0,0 +1,579 @@
+// Package idx reads and writes files in the IDX format, the format of the
+// MNIST database and of the data sets derived from it (Fashion-MNIST, KMNIST,
+// EMNIST).
+//
+// An IDX file is a big-endian header followed by the elements of a
+// multi-dimensional array in row-major order:
+//
+//	magic  [4]byte  0, 0, element type, number of dimensions
+//	dims   number of dimensions x uint32
+//	data   the elements, big-endian
+//
+// Files can be read whole (Read, ReadFile) or one record at a time (Reader),
+// where a record is everything under one index of the first dimension (one
+// image of an image file). Gzip compressed files are recognised and
+// decompressed automatically.
+package idx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrFormat indicates that the data is not a valid IDX file.
+	ErrFormat = errors.New("idx: invalid format")
+
+	// ErrType indicates a slice whose Go type doesn't match the element type.
+	ErrType = errors.New("idx: wrong slice type")
+)
+
+// Type is the element type of an IDX file, as stored in its third magic byte.
+type Type byte
+
+// The element types of the format and the Go types of their slices.
+const (
+	Ubyte  Type = 0x08 // []uint8
+	Byte   Type = 0x09 // []int8
+	Short  Type = 0x0B // []int16
+	Int    Type = 0x0C // []int32
+	Float  Type = 0x0D // []float32
+	Double Type = 0x0E // []float64
+)
+
+// Types lists every element type.
+var Types = []Type{Ubyte, Byte, Short, Int, Float, Double}
+
+// Size returns the number of bytes an element takes in a file, or 0 for an
+// unknown type.
+func (t Type) Size() int {
+	switch t {
+	case Ubyte, Byte:
+		return 1
+	case Short:
+		return 2
+	case Int, Float:
+		return 4
+	case Double:
+		return 8
+	}
+	return 0
+}
+
+func (t Type) String() string {
+	switch t {
+	case Ubyte:
+		return "ubyte"
+	case Byte:
+		return "byte"
+	case Short:
+		return "short"
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Double:
+		return "double"
+	}
+	return fmt.Sprintf("Type(%#02x)", byte(t))
+}
+
+// MaxRank is the largest number of dimensions the format can describe.
+const MaxRank = math.MaxUint8
+
+// Header describes the array stored in an IDX file.
+type Header struct {
+	Type Type
+	Dims []int
+}
+
+// Len returns the number of elements of the array.
+func (h Header) Len() int {
+	n := 1
+	for _, dim := range h.Dims {
+		n *= dim
+	}
+	return n
+}
+
+// Records returns the size of the first dimension: the number of records.
+// An array of rank 0 holds a single record.
+func (h Header) Records() int {
+	if len(h.Dims) == 0 {
+		return 1
+	}
+	return h.Dims[0]
+}
+
+// RecordLen returns the number of elements in a record: the product of every
+// dimension but the first.
+func (h Header) RecordLen() int {
+	n := 1
+	for i := 1; i < len(h.Dims); i++ {
+		n *= h.Dims[i]
+	}
+	return n
+}
+
+// validate checks that the header can be written and that the size of the
+// whole array in bytes fits in an int64.
+func (h Header) validate() error {
+	size := h.Type.Size()
+	if size == 0 {
+		return fmt.Errorf("%w: unknown element type %#02x", ErrFormat, byte(h.Type))
+	}
+	if len(h.Dims) > MaxRank {
+		return fmt.Errorf("%w: %d dimensions", ErrFormat, len(h.Dims))
+	}
+	empty := false
+	for _, dim := range h.Dims {
+		if dim < 0 || uint64(dim) > math.MaxUint32 {
+			return fmt.Errorf("%w: dimension %d", ErrFormat, dim)
+		}
+		empty = empty || dim == 0
+	}
+	if empty {
+		return nil
+	}
+	bytes := uint64(size)
+	for _, dim := range h.Dims {
+		if bytes > math.MaxInt64/uint64(dim) {
+			return fmt.Errorf("%w: %v elements of %s are too large", ErrFormat, h.Dims, h.Type)
+		}
+		bytes *= uint64(dim)
+	}
+	return nil
+}
+
+// Array is a whole IDX file in memory.
+type Array struct {
+	Header
+	// Data holds every element in row-major order, in a slice of the Go type
+	// of Type ([]uint8 for Ubyte, []int8 for Byte, ... []float64 for Double).
+	Data interface{}
+}
+
+// NewArray returns a zeroed array with the given element type and dimensions.
+func NewArray(t Type, dims ...int) (*Array, error) {
+	header := Header{Type: t, Dims: append([]int{}, dims...)}
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+	return &Array{Header: header, Data: makeSlice(t, header.Len())}, nil
+}
+
+// Float64s returns a copy of the elements converted to float64.
+func (a *Array) Float64s() []float64 {
+	res := make([]float64, sliceLen(a.Data))
+	switch data := a.Data.(type) {
+	case []uint8:
+		for i, v := range data {
+			res[i] = float64(v)
+		}
+	case []int8:
+		for i, v := range data {
+			res[i] = float64(v)
+		}
+	case []int16:
+		for i, v := range data {
+			res[i] = float64(v)
+		}
+	case []int32:
+		for i, v := range data {
+			res[i] = float64(v)
+		}
+	case []float32:
+		for i, v := range data {
+			res[i] = float64(v)
+		}
+	case []float64:
+		copy(res, data)
+	}
+	return res
+}
+
+// Reader reads an IDX file one record at a time.
+type Reader struct {
+	Header
+	r    io.Reader
+	read int    // records read so far
+	buf  []byte // the encoded records of the last read
+}
+
+// NewReader reads the header of an IDX file from r, decompressing it first if
+// it is gzipped.
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		r = decompressed
+	} else {
+		r = buffered
+	}
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if magic[0] != 0 || magic[1] != 0 {
+		return nil, fmt.Errorf("%w: magic %#x", ErrFormat, magic)
+	}
+	header := Header{Type: Type(magic[2]), Dims: make([]int, magic[3])}
+	if header.Type.Size() == 0 {
+		return nil, fmt.Errorf("%w: unknown element type %#02x", ErrFormat, magic[2])
+	}
+	for i := range header.Dims {
+		var dim uint32
+		if err := binary.Read(r, binary.BigEndian, &dim); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		header.Dims[i] = int(dim)
+	}
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+	return &Reader{Header: header, r: r}, nil
+}
+
+// ReadRecord decodes the next record into dst, a slice of the Go type of the
+// element type with room for RecordLen elements. It returns io.EOF after the
+// last record and io.ErrUnexpectedEOF if the file ends inside a record.
+func (r *Reader) ReadRecord(dst interface{}) error {
+	if sliceLen(dst) < r.RecordLen() {
+		return fmt.Errorf("%w: can't read %d elements of %s into %T of length %d", ErrType, r.RecordLen(), r.Type, dst, sliceLen(dst))
+	}
+	_, err := r.ReadRecords(subslice(dst, 0, r.RecordLen()))
+	return err
+}
+
+// ReadRecords decodes as many of the remaining records as fit in dst, a slice
+// of the Go type of the element type, and returns how many it read. The whole
+// block is read with a single io.ReadFull; unsigned bytes are read straight
+// into dst. It returns io.EOF once every record has been read and
+// io.ErrUnexpectedEOF if the file ends inside the block.
+func (r *Reader) ReadRecords(dst interface{}) (int, error) {
+	if r.read == r.Records() {
+		return 0, io.EOF
+	}
+	n := r.Records() - r.read
+	if r.RecordLen() > 0 && sliceLen(dst)/r.RecordLen() < n {
+		n = sliceLen(dst) / r.RecordLen()
+	}
+	if sliceType(dst) != r.Type || n == 0 {
+		return 0, fmt.Errorf("%w: can't read %d elements of %s into %T of length %d", ErrType, r.RecordLen(), r.Type, dst, sliceLen(dst))
+	}
+
+	if bytes, ok := dst.([]uint8); ok {
+		if _, err := io.ReadFull(r.r, bytes[:n*r.RecordLen()]); err != nil {
+			return 0, unexpectedEOF(err)
+		}
+	} else {
+		size := n * r.RecordLen() * r.Type.Size()
+		if cap(r.buf) < size {
+			r.buf = make([]byte, size)
+		}
+		buf := r.buf[:size]
+		if _, err := io.ReadFull(r.r, buf); err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		decode(buf, dst)
+	}
+	r.read += n
+	return n, nil
+}
+
+// ReadAll reads every record that hasn't been read yet.
+// Memory is only allocated for data that is actually there, so a header that
+// claims more records than the file holds can't make it allocate the difference.
+func (r *Reader) ReadAll() (*Array, error) {
+	header := Header{Type: r.Type, Dims: append([]int{}, r.Dims...)}
+	if len(header.Dims) > 0 {
+		header.Dims[0] = r.Records() - r.read
+	}
+	size := int64(header.Len()) * int64(r.Type.Size())
+
+	var buf bytes.Buffer
+	if n, err := io.CopyN(&buf, r.r, size); err != nil {
+		if err == io.EOF && n < size {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	r.read = r.Records()
+
+	data := buf.Bytes()
+	if r.Type == Ubyte {
+		return &Array{Header: header, Data: data}, nil
+	}
+	array := &Array{Header: header, Data: makeSlice(r.Type, header.Len())}
+	decode(data, array.Data)
+	return array, nil
+}
+
+// Read reads a whole IDX file from r, which may be gzipped.
+func Read(r io.Reader) (*Array, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return reader.ReadAll()
+}
+
+// ReadFile reads the whole IDX file name, which may be gzipped.
+func ReadFile(name string) (*Array, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Writer writes an IDX file one record at a time.
+type Writer struct {
+	Header
+	w       *bufio.Writer
+	written int    // records written so far
+	buf     []byte // the encoded record
+}
+
+// NewWriter writes the header of an array with the given element type and
+// dimensions to w. Wrap w in a gzip.Writer to compress the file.
+func NewWriter(w io.Writer, t Type, dims ...int) (*Writer, error) {
+	header := Header{Type: t, Dims: append([]int{}, dims...)}
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+	writer := &Writer{Header: header, w: bufio.NewWriter(w)}
+	magic := [4]byte{0, 0, byte(t), byte(len(dims))}
+	if _, err := writer.w.Write(magic[:]); err != nil {
+		return nil, err
+	}
+	for _, dim := range dims {
+		if err := binary.Write(writer.w, binary.BigEndian, uint32(dim)); err != nil {
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+// WriteRecord encodes the first RecordLen elements of src, a slice of the Go
+// type of the element type, as the next record.
+func (w *Writer) WriteRecord(src interface{}) error {
+	if w.written == w.Records() {
+		return fmt.Errorf("idx: all %d records have already been written", w.Records())
+	}
+	if sliceType(src) != w.Type || sliceLen(src) < w.RecordLen() {
+		return fmt.Errorf("%w: can't write %d elements of %s from %T of length %d", ErrType, w.RecordLen(), w.Type, src, sliceLen(src))
+	}
+	size := w.RecordLen() * w.Type.Size()
+	if cap(w.buf) < size {
+		w.buf = make([]byte, size)
+	}
+	buf := w.buf[:size]
+	encode(src, buf)
+	if _, err := w.w.Write(buf); err != nil {
+		return err
+	}
+	w.written++
+	return nil
+}
+
+// Close flushes the file. It fails if fewer records were written than the
+// header announced. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.written != w.Records() {
+		return fmt.Errorf("idx: %d of %d records written", w.written, w.Records())
+	}
+	return w.w.Flush()
+}
+
+// Write writes the array to w as an IDX file.
+func Write(w io.Writer, a *Array) error {
+	if sliceType(a.Data) != a.Type || sliceLen(a.Data) != a.Len() {
+		return fmt.Errorf("%w: %T of length %d for %v elements of %s", ErrType, a.Data, sliceLen(a.Data), a.Dims, a.Type)
+	}
+	writer, err := NewWriter(w, a.Type, a.Dims...)
+	if err != nil {
+		return err
+	}
+	recordLen := a.RecordLen()
+	for i := 0; i < a.Records(); i++ {
+		if err := writer.WriteRecord(subslice(a.Data, i*recordLen, (i+1)*recordLen)); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// WriteFile writes the array to the file name, gzipped if the name ends in
+// ".gz".
+func WriteFile(name string, a *Array) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = file
+	var compressed *gzip.Writer
+	if strings.HasSuffix(name, ".gz") {
+		compressed = gzip.NewWriter(file)
+		w = compressed
+	}
+	err = Write(w, a)
+	if compressed != nil && err == nil {
+		err = compressed.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// makeSlice returns a zeroed slice of n elements of the Go type of t.
+func makeSlice(t Type, n int) interface{} {
+	switch t {
+	case Ubyte:
+		return make([]uint8, n)
+	case Byte:
+		return make([]int8, n)
+	case Short:
+		return make([]int16, n)
+	case Int:
+		return make([]int32, n)
+	case Float:
+		return make([]float32, n)
+	case Double:
+		return make([]float64, n)
+	}
+	return nil
+}
+
+// sliceType returns the element type whose Go type is the type of the slice,
+// or 0 for any other type.
+func sliceType(data interface{}) Type {
+	switch data.(type) {
+	case []uint8:
+		return Ubyte
+	case []int8:
+		return Byte
+	case []int16:
+		return Short
+	case []int32:
+		return Int
+	case []float32:
+		return Float
+	case []float64:
+		return Double
+	}
+	return 0
+}
+
+func sliceLen(data interface{}) int {
+	switch data := data.(type) {
+	case []uint8:
+		return len(data)
+	case []int8:
+		return len(data)
+	case []int16:
+		return len(data)
+	case []int32:
+		return len(data)
+	case []float32:
+		return len(data)
+	case []float64:
+		return len(data)
+	}
+	return 0
+}
+
+func subslice(data interface{}, lo, hi int) interface{} {
+	switch data := data.(type) {
+	case []uint8:
+		return data[lo:hi]
+	case []int8:
+		return data[lo:hi]
+	case []int16:
+		return data[lo:hi]
+	case []int32:
+		return data[lo:hi]
+	case []float32:
+		return data[lo:hi]
+	case []float64:
+		return data[lo:hi]
+	}
+	return nil
+}
+
+// decode converts the big-endian elements in src into the slice dst.
+func decode(src []byte, dst interface{}) {
+	switch dst := dst.(type) {
+	case []uint8:
+		copy(dst, src)
+	case []int8:
+		for i, v := range src {
+			dst[i] = int8(v)
+		}
+	case []int16:
+		for i := range dst[:len(src)/2] {
+			dst[i] = int16(binary.BigEndian.Uint16(src[2*i:]))
+		}
+	case []int32:
+		for i := range dst[:len(src)/4] {
+			dst[i] = int32(binary.BigEndian.Uint32(src[4*i:]))
+		}
+	case []float32:
+		for i := range dst[:len(src)/4] {
+			dst[i] = math.Float32frombits(binary.BigEndian.Uint32(src[4*i:]))
+		}
+	case []float64:
+		for i := range dst[:len(src)/8] {
+			dst[i] = math.Float64frombits(binary.BigEndian.Uint64(src[8*i:]))
+		}
+	}
+}
+
+// encode converts the elements of the slice src into big-endian bytes in dst.
+func encode(src interface{}, dst []byte) {
+	switch src := src.(type) {
+	case []uint8:
+		copy(dst, src)
+	case []int8:
+		for i := range dst {
+			dst[i] = uint8(src[i])
+		}
+	case []int16:
+		for i := 0; i < len(dst)/2; i++ {
+			binary.BigEndian.PutUint16(dst[2*i:], uint16(src[i]))
+		}
+	case []int32:
+		for i := 0; i < len(dst)/4; i++ {
+			binary.BigEndian.PutUint32(dst[4*i:], uint32(src[i]))
+		}
+	case []float32:
+		for i := 0; i < len(dst)/4; i++ {
+			binary.BigEndian.PutUint32(dst[4*i:], math.Float32bits(src[i]))
+		}
+	case []float64:
+		for i := 0; i < len(dst)/8; i++ {
+			binary.BigEndian.PutUint64(dst[8*i:], math.Float64bits(src[i]))
+		}
+	}
+}
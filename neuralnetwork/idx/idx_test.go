@@ -0,0 +1,176 @@
+package idx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestRoundTrip writes random arrays of every element type and a range of
+// ranks, gzipped and plain, and reads them back whole and record by record.
+func TestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, typ := range Types {
+		for _, dims := range [][]int{{}, {7}, {5, 3}, {4, 3, 2}, {2, 1, 3, 2}, {0, 3}} {
+			for _, compress := range []bool{false, true} {
+				array, err := NewArray(typ, dims...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fillRandom(array.Data, rng)
+				file := writeFile(t, array, compress)
+
+				read, err := Read(bytes.NewReader(file))
+				if err != nil {
+					t.Errorf("%s %v gzip=%v: Read: %v", typ, dims, compress, err)
+					continue
+				}
+				if read.Type != typ || !reflect.DeepEqual(read.Dims, array.Dims) || !sameBits(read.Data, array.Data) {
+					t.Errorf("%s %v gzip=%v: Read gave %s %v %v, want %v", typ, dims, compress, read.Type, read.Dims, read.Data, array.Data)
+				}
+
+				records, err := readRecords(file, reflect.TypeOf(array.Data))
+				if err != nil {
+					t.Errorf("%s %v gzip=%v: ReadRecord: %v", typ, dims, compress, err)
+					continue
+				}
+				if !sameBits(records, array.Data) {
+					t.Errorf("%s %v gzip=%v: ReadRecord gave %v, want %v", typ, dims, compress, records, array.Data)
+				}
+			}
+		}
+	}
+}
+
+// TestTruncated checks that a file cut off anywhere fails to read rather than
+// coming back short, with io.ErrUnexpectedEOF once the header is complete.
+func TestTruncated(t *testing.T) {
+	array, err := NewArray(Short, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := writeFile(t, array, false)
+	header := 4 + 4*len(array.Dims)
+	for n := 0; n < len(file); n++ {
+		_, err := Read(bytes.NewReader(file[:n]))
+		if err == nil {
+			t.Errorf("reading %d of %d bytes succeeded", n, len(file))
+		} else if n >= header && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("reading %d of %d bytes: got error %v, want %v", n, len(file), err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+// writeFile returns array as an IDX file, gzipped if compress is set.
+func writeFile(t *testing.T, array *Array, compress bool) []byte {
+	t.Helper()
+	var file bytes.Buffer
+	var w io.Writer = &file
+	var compressed *gzip.Writer
+	if compress {
+		compressed = gzip.NewWriter(&file)
+		w = compressed
+	}
+	if err := Write(w, array); err != nil {
+		t.Fatal(err)
+	}
+	if compressed != nil {
+		if err := compressed.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return file.Bytes()
+}
+
+// readRecords reads file one record at a time and returns the records joined
+// into one slice of sliceType.
+func readRecords(file []byte, sliceType reflect.Type) (interface{}, error) {
+	reader, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	records := reflect.MakeSlice(sliceType, 0, reader.Len())
+	record := reflect.MakeSlice(sliceType, reader.RecordLen(), reader.RecordLen())
+	for {
+		err := reader.ReadRecord(record.Interface())
+		if err == io.EOF {
+			return records.Interface(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = reflect.AppendSlice(records, record)
+	}
+}
+
+// fillRandom fills a slice of any element type with random values, including
+// extremes, NaN and infinities.
+func fillRandom(data interface{}, rng *rand.Rand) {
+	switch data := data.(type) {
+	case []uint8:
+		rng.Read(data)
+	case []int8:
+		for i := range data {
+			data[i] = int8(rng.Intn(256) - 128)
+		}
+	case []int16:
+		for i := range data {
+			data[i] = int16(rng.Intn(1<<16) - 1<<15)
+		}
+	case []int32:
+		for i := range data {
+			data[i] = int32(rng.Uint32())
+		}
+	case []float32:
+		specials := []float32{float32(math.NaN()), float32(math.Inf(1)), float32(math.Inf(-1)), math.MaxFloat32, math.SmallestNonzeroFloat32}
+		for i := range data {
+			data[i] = float32(rng.NormFloat64())
+			if i < len(specials) {
+				data[i] = specials[i]
+			}
+		}
+	case []float64:
+		specials := []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.MaxFloat64, math.SmallestNonzeroFloat64}
+		for i := range data {
+			data[i] = rng.NormFloat64()
+			if i < len(specials) {
+				data[i] = specials[i]
+			}
+		}
+	}
+}
+
+// sameBits compares two slices element by element; floats by their bits, so
+// NaNs compare equal.
+func sameBits(a interface{}, b interface{}) bool {
+	switch a := a.(type) {
+	case []float32:
+		b := b.([]float32)
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if math.Float32bits(a[i]) != math.Float32bits(b[i]) {
+				return false
+			}
+		}
+		return true
+	case []float64:
+		b := b.([]float64)
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if math.Float64bits(a[i]) != math.Float64bits(b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
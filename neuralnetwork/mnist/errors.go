@@ -0,0 +1,88 @@
+package mnist
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The reasons an image or label file can be rejected for. A *ParseError
+// wraps one of them, so they can be told apart with errors.Is.
+var (
+	// ErrMagic indicates that the file doesn't start with the magic number of
+	// an image (0x00000803) or label (0x00000801) file.
+	ErrMagic = errors.New("wrong magic number")
+
+	// ErrDims indicates images that are not Height x Width pixels.
+	ErrDims = errors.New("wrong image dimensions")
+
+	// ErrTruncated indicates a file that ends before all of the images or
+	// labels its header announces.
+	ErrTruncated = errors.New("truncated file")
+
+	// ErrLabel indicates a label outside the range of its data set.
+	ErrLabel = errors.New("label out of range")
+
+	// ErrCount indicates a header that announces more than MaxCount images or
+	// labels.
+	ErrCount = errors.New("absurd count")
+)
+
+// MaxCount is the largest number of images or labels a file may hold, so a
+// corrupt header can't ask for more memory than any of the data sets need
+// (the largest, EMNIST ByClass, has 697932 training images).
+const MaxCount = 1 << 22
+
+// ParseError describes an image or label file that can't be parsed.
+// errors.Is(err, ErrFormat) holds for every ParseError.
+type ParseError struct {
+	File   string // the file name, if the data came from a file
+	Err    error  // ErrMagic, ErrDims, ErrTruncated, ErrLabel or ErrCount
+	Detail string // what exactly was wrong
+}
+
+func (e *ParseError) Error() string {
+	msg := "mnist: "
+	if e.File != "" {
+		msg += e.File + ": "
+	}
+	msg += e.Err.Error()
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Unwrap returns the reason the file was rejected for.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports every ParseError as an ErrFormat.
+func (e *ParseError) Is(target error) bool {
+	return target == ErrFormat
+}
+
+func parseError(err error, format string, args ...interface{}) *ParseError {
+	return &ParseError{Err: err, Detail: fmt.Sprintf(format, args...)}
+}
+
+// withFile records the file name in a ParseError; other errors are returned
+// as they are.
+func withFile(err error, name string) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.File = name
+	}
+	return err
+}
+
+// CheckLabels returns a ParseError for the first label outside [lo, hi], or
+// nil if there is none.
+func CheckLabels(labels []Label, lo, hi Label) error {
+	for i, label := range labels {
+		if label < lo || label > hi {
+			return parseError(ErrLabel, "label %d of sample %d isn't in [%d, %d]", label, i, lo, hi)
+		}
+	}
+	return nil
+}
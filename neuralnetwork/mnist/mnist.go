@@ -1,4 +1,5 @@
 // ALL CODE BELOW OBTAINED FROM https://github.com/moverest/mnist
+// (except the file parsing, which is now done by the idx package)
 
 // Copyright 2016 Clément Martinez
 
@@ -12,18 +13,21 @@
 package mnist
 
 import (
-	"compress/gzip"
-	"encoding/binary"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"io"
+	"math"
 	"os"
 	"path"
+	"proj3/idx"
+	"sync"
 )
 
 var (
 	// ErrFormat indicates that the file has not been recognised.
+	// Every *ParseError matches it.
 	ErrFormat = errors.New("mnist: invalid format")
 
 	// ErrSize indicates that the labels and images count mismatch.
@@ -57,32 +61,15 @@ type Set struct {
 	Labels []Label
 }
 
-type imageFileHeader struct {
-	Magic     int32
-	NumImages int32
-	Height    int32
-	Width     int32
-}
-
-type labelFileHeader struct {
-	Magic     int32
-	NumLabels int32
-}
-
-// Magic keys are used to check file formats.
+// Magic numbers of the image and label files: unsigned bytes (0x08) in three
+// and one dimensions.
 const (
 	imageMagic = 0x00000803
 	labelMagic = 0x00000801
 )
 
-// readImage reads a image from the file and returns it.
-func readImage(r io.Reader) (*Image, error) {
-	img := &Image{}
-	err := binary.Read(r, binary.BigEndian, img)
-	return img, err
-}
-
 // LoadImageFile opens the image file, parses it, and returns the data in order.
+// The file may be gzipped or not.
 func LoadImageFile(name string) ([]*Image, error) {
 	file, err := os.Open(name)
 	if err != nil {
@@ -90,37 +77,53 @@ func LoadImageFile(name string) ([]*Image, error) {
 	}
 	defer file.Close()
 
-	reader, err := gzip.NewReader(file)
-	if err != nil {
-		return nil, err
-	}
-
-	header := imageFileHeader{}
+	images, err := ReadImages(file)
+	return images, withFile(err, name)
+}
 
-	err = binary.Read(reader, binary.BigEndian, &header)
+// ReadImages parses an image file from r: an IDX file of unsigned bytes with
+// dimensions count x Height x Width. A file that doesn't match is rejected
+// with a *ParseError.
+func ReadImages(r io.Reader) ([]*Image, error) {
+	reader, err := newReader(r, imageMagic)
 	if err != nil {
 		return nil, err
 	}
 
-	if header.Magic != imageMagic ||
-		header.Width != Width ||
-		header.Height != header.Height {
-		return nil, ErrFormat
+	if reader.Dims[1] != Height || reader.Dims[2] != Width {
+		return nil, parseError(ErrDims, "%dx%d, want %dx%d", reader.Dims[1], reader.Dims[2], Height, Width)
 	}
 
-	images := make([]*Image, header.NumImages)
-	for i := int32(0); i < header.NumImages; i++ {
-		images[i], err = readImage(reader)
+	// the pixels of every image, back to back, read a block of images at a time
+	// the header's count is only trusted as far as the data goes:
+	// the buffer starts small and doubles as long as images keep arriving
+	count := reader.Records()
+	size := len(Image{})
+	pixels := make([]byte, 0, min(count, 64)*size)
+	for len(pixels) < count*size {
+		if len(pixels) == cap(pixels) {
+			grown := make([]byte, len(pixels), min(2*cap(pixels), count*size))
+			copy(grown, pixels)
+			pixels = grown
+		}
+		n, err := reader.ReadRecords(pixels[len(pixels):cap(pixels)])
 		if err != nil {
-			return nil, err
+			return nil, truncated(err, "images %d to %d of %d", len(pixels)/size, cap(pixels)/size, count)
 		}
+		pixels = pixels[:len(pixels)+n*size]
+	}
+
+	// every Image is a view of its pixels in the shared buffer
+	images := make([]*Image, count)
+	for i := range images {
+		images[i] = (*Image)(pixels[i*size : (i+1)*size])
 	}
 
 	return images, nil
 }
 
 // LoadLabelFile opens the label file, parses it, and returns the labels in
-// order.
+// order. The file may be gzipped or not.
 func LoadLabelFile(name string) ([]Label, error) {
 	file, err := os.Open(name)
 	if err != nil {
@@ -128,31 +131,71 @@ func LoadLabelFile(name string) ([]Label, error) {
 	}
 	defer file.Close()
 
-	reader, err := gzip.NewReader(file)
+	labels, err := ReadLabels(file)
+	return labels, withFile(err, name)
+}
+
+// ReadLabels parses a label file from r: a one-dimensional IDX file of
+// unsigned bytes. Every label must fit a Label (0 to 127). A file that doesn't
+// match is rejected with a *ParseError.
+func ReadLabels(r io.Reader) ([]Label, error) {
+	reader, err := newReader(r, labelMagic)
 	if err != nil {
 		return nil, err
 	}
 
-	header := labelFileHeader{}
+	count := reader.Records()
+	array, err := reader.ReadAll()
+	if err != nil {
+		return nil, truncated(err, "%d labels", count)
+	}
+
+	data := array.Data.([]uint8)
+	labels := make([]Label, len(data))
+	for i, v := range data {
+		if v > math.MaxInt8 {
+			return nil, parseError(ErrLabel, "label %d of sample %d", v, i)
+		}
+		labels[i] = Label(v)
+	}
 
-	err = binary.Read(reader, binary.BigEndian, &header)
+	return labels, nil
+}
+
+// newReader reads the IDX header from r and checks its magic number and count.
+func newReader(r io.Reader, magic uint32) (*idx.Reader, error) {
+	reader, err := idx.NewReader(r)
+	if errors.Is(err, idx.ErrFormat) {
+		return nil, parseError(ErrMagic, "%v", err)
+	}
 	if err != nil {
-		return nil, err
+		return nil, truncated(err, "header")
 	}
 
-	if header.Magic != labelMagic {
-		return nil, err
+	got := uint32(reader.Type)<<8 | uint32(len(reader.Dims))
+	if got != magic {
+		return nil, parseError(ErrMagic, "%#08x, want %#08x", got, magic)
 	}
+	if reader.Records() > MaxCount {
+		return nil, parseError(ErrCount, "%d, at most %d", reader.Records(), MaxCount)
+	}
+	return reader, nil
+}
 
-	labels := make([]Label, header.NumLabels)
-	for i := int32(0); i < header.NumLabels; i++ {
-		err = binary.Read(reader, binary.BigEndian, &labels[i])
-		if err != nil {
-			return nil, err
-		}
+// truncated turns a file that ended early into a ParseError; other errors
+// (failing reads, corrupt gzip data) are returned as they are.
+func truncated(err error, format string, args ...interface{}) error {
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return parseError(ErrTruncated, "in "+format, args...)
 	}
+	return err
+}
 
-	return labels, nil
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
 
 // ColorModel implements the image.Image interface.
@@ -181,26 +224,11 @@ func (img *Image) Set(x, y int, v byte) {
 // LoadSet loads the images and labels, check if the counts match and returns
 // a set.
 func LoadSet(imageName, labelName string) (*Set, error) {
-	images, err := LoadImageFile(imageName)
+	sets, err := LoadSets(false, [2]string{imageName, labelName})
 	if err != nil {
 		return nil, err
 	}
-
-	labels, err := LoadLabelFile(labelName)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(images) != len(labels) {
-		return nil, ErrSize
-	}
-
-	set := &Set{
-		Images: images,
-		Labels: labels,
-	}
-
-	return set, nil
+	return sets[0], nil
 }
 
 // Count returns the number of images and labels in the set.
@@ -213,19 +241,68 @@ func (s *Set) Get(i int) (*Image, Label) {
 	return s.Images[i], s.Labels[i]
 }
 
+// LoadSets loads several sets, each from an image and a label file name.
+// With concurrent set, every file is decompressed and parsed in its own
+// goroutine. Decompressing is most of the work of loading, and a gzip stream
+// can only be decompressed from start to end, so files are the unit of
+// parallelism. The first error, in file order, is returned.
+func LoadSets(concurrent bool, names ...[2]string) ([]*Set, error) {
+	images := make([][]*Image, len(names))
+	labels := make([][]Label, len(names))
+	errs := make([]error, 2*len(names))
+
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		if !concurrent {
+			f()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	for i := range names {
+		i := i
+		run(func() { images[i], errs[2*i] = LoadImageFile(names[i][0]) })
+		run(func() { labels[i], errs[2*i+1] = LoadLabelFile(names[i][1]) })
+	}
+	wg.Wait()
+
+	sets := make([]*Set, len(names))
+	for i := range names {
+		for _, err := range errs[2*i : 2*i+2] {
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(images[i]) != len(labels[i]) {
+			return nil, fmt.Errorf("%w: %d images and %d labels", ErrSize, len(images[i]), len(labels[i]))
+		}
+		sets[i] = &Set{Images: images[i], Labels: labels[i]}
+	}
+	return sets, nil
+}
+
 // Load loads the whole MINST database and returns the training set and the test
-// set.
+// set. Every label must be a digit. The four files are loaded concurrently.
 func Load(dir string) (training, test *Set, err error) {
-	training, err = LoadSet(path.Join(dir, TrainingImageFileName),
-		path.Join(dir, TrainingLabelFileName))
+	sets, err := LoadSets(true,
+		[2]string{path.Join(dir, TrainingImageFileName), path.Join(dir, TrainingLabelFileName)},
+		[2]string{path.Join(dir, TestImageFileName), path.Join(dir, TestLabelFileName)})
 	if err != nil {
 		return nil, nil, err
 	}
+	training, test = sets[0], sets[1]
 
-	test, err = LoadSet(path.Join(dir, TestImageFileName),
-		path.Join(dir, TestLabelFileName))
+	err = CheckLabels(training.Labels, 0, 9)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, withFile(err, path.Join(dir, TrainingLabelFileName))
+	}
+	err = CheckLabels(test.Labels, 0, 9)
+	if err != nil {
+		return nil, nil, withFile(err, path.Join(dir, TestLabelFileName))
 	}
 
 	return
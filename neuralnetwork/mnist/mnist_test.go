@@ -0,0 +1,271 @@
+package mnist
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"proj3/idx"
+)
+
+// TestParseErrors checks that every way a file can be malformed is reported
+// with its own error, as a *ParseError and an ErrFormat.
+func TestParseErrors(t *testing.T) {
+	images, labels := mnistFiles(t, 10)
+	with := func(file []byte, offset int, value uint32) []byte {
+		res := append([]byte{}, file...)
+		binary.BigEndian.PutUint32(res[offset:], value)
+		return res
+	}
+	labels200 := append([]byte{}, labels...)
+	labels200[len(labels200)-1] = 200
+
+	cases := []struct {
+		name   string
+		file   []byte
+		labels bool
+		want   error
+	}{
+		{"label magic in an image file", with(images, 0, 0x801), false, ErrMagic},
+		{"image magic in a label file", with(labels, 0, 0x803), true, ErrMagic},
+		{"not an IDX file", []byte("PK\x03\x04 not an idx file"), false, ErrMagic},
+		{"float images", with(images, 0, 0x0d03), false, ErrMagic},
+		{"27 pixels wide", with(images, 12, 27), false, ErrDims},
+		{"empty file", nil, false, ErrTruncated},
+		{"truncated header", images[:10], false, ErrTruncated},
+		{"truncated images", images[:len(images)-1], false, ErrTruncated},
+		{"truncated gzipped images", gzipped(t, images)[:200], false, ErrTruncated},
+		{"truncated labels", labels[:len(labels)-1], true, ErrTruncated},
+		{"label 200", labels200, true, ErrLabel},
+		{"4 billion images", with(images, 4, math.MaxUint32), false, ErrCount},
+		{"4 billion labels", with(labels, 4, math.MaxUint32), true, ErrCount},
+	}
+	for _, c := range cases {
+		var err error
+		if c.labels {
+			_, err = ReadLabels(bytes.NewReader(c.file))
+		} else {
+			_, err = ReadImages(bytes.NewReader(c.file))
+		}
+		var parseErr *ParseError
+		if !errors.Is(err, c.want) || !errors.Is(err, ErrFormat) || !errors.As(err, &parseErr) {
+			t.Errorf("%s: got error %v, want a ParseError for %v", c.name, err, c.want)
+		}
+	}
+	if err := CheckLabels([]Label{0, 9, 10}, 0, 9); !errors.Is(err, ErrLabel) {
+		t.Errorf("label 10 of a digit: got error %v, want %v", err, ErrLabel)
+	}
+}
+
+// FuzzReadImages checks that ReadImages never panics on a damaged file,
+// rejects it with a ParseError or a gzip error, returns no nil images, and
+// allocates no more than a constant plus a multiple of the file's size,
+// whatever its header claims.
+//
+//	go test -fuzz FuzzReadImages ./mnist
+func FuzzReadImages(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, file []byte) {
+		var images []*Image
+		var err error
+		allocated := allocatedBy(func() { images, err = ReadImages(bytes.NewReader(file)) })
+		checkParse(t, file, err, allocated)
+		for i, image := range images {
+			if image == nil {
+				t.Fatalf("image %d of %d is nil", i, len(images))
+			}
+		}
+	})
+}
+
+// FuzzReadLabels is FuzzReadImages for ReadLabels; every label it returns
+// must fit a Label.
+//
+//	go test -fuzz FuzzReadLabels ./mnist
+func FuzzReadLabels(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, file []byte) {
+		var labels []Label
+		var err error
+		allocated := allocatedBy(func() { labels, err = ReadLabels(bytes.NewReader(file)) })
+		checkParse(t, file, err, allocated)
+		for i, label := range labels {
+			if label < 0 {
+				t.Fatalf("label %d of sample %d", label, i)
+			}
+		}
+	})
+}
+
+// addSeeds seeds a fuzz target with valid image and label files, plain and
+// gzipped.
+func addSeeds(f *testing.F) {
+	images, labels := mnistFiles(f, 4)
+	for _, file := range [][]byte{images, labels, gzipped(f, images), gzipped(f, labels)} {
+		f.Add(file)
+	}
+}
+
+// checkParse fails t if a parser rejected file with an error other than a
+// ParseError or one from gzip, or allocated more than 1 MiB plus 16 bytes per
+// byte of the file (1100 for a gzipped one, bounded by how far deflate can
+// expand it).
+func checkParse(t *testing.T, file []byte, err error, allocated uint64) {
+	var corrupt flate.CorruptInputError
+	if err != nil && !errors.Is(err, ErrFormat) && !errors.Is(err, gzip.ErrHeader) && !errors.Is(err, gzip.ErrChecksum) && !errors.As(err, &corrupt) {
+		t.Errorf("unexpected error %v (%T)", err, err)
+	}
+	limit := uint64(1<<20 + 16*len(file))
+	if bytes.HasPrefix(file, []byte{0x1f, 0x8b}) {
+		limit = uint64(1<<20 + 1100*len(file))
+	}
+	if allocated > limit {
+		t.Errorf("allocated %d bytes for a %d byte file", allocated, len(file))
+	}
+}
+
+// allocatedBy returns the bytes f allocates.
+func allocatedBy(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+// mnistFiles returns a valid image file of n random images and a valid label
+// file of n labels, uncompressed. Like MNIST's digits, about four in five
+// pixels are background (0), so the files compress about as well as MNIST's.
+func mnistFiles(tb testing.TB, n int) ([]byte, []byte) {
+	tb.Helper()
+	rng := rand.New(rand.NewSource(1))
+	images, err := idx.NewArray(idx.Ubyte, n, Height, Width)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := range images.Data.([]uint8) {
+		if rng.Intn(5) == 0 {
+			images.Data.([]uint8)[i] = uint8(1 + rng.Intn(255))
+		}
+	}
+	labels, err := idx.NewArray(idx.Ubyte, n)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := range labels.Data.([]uint8) {
+		labels.Data.([]uint8)[i] = uint8(rng.Intn(10))
+	}
+	var imageFile, labelFile bytes.Buffer
+	if err := idx.Write(&imageFile, images); err != nil {
+		tb.Fatal(err)
+	}
+	if err := idx.Write(&labelFile, labels); err != nil {
+		tb.Fatal(err)
+	}
+	return imageFile.Bytes(), labelFile.Bytes()
+}
+
+func gzipped(tb testing.TB, data []byte) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		tb.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// the parser before the idx package: binary.Read for every image of a
+// gzipped image file
+func legacyReadImages(r io.Reader) ([]*Image, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var header [4]int32
+	if err := binary.Read(gz, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header[0] != imageMagic || header[2] != Height || header[3] != Width {
+		return nil, ErrFormat
+	}
+	images := make([]*Image, header[1])
+	for i := range images {
+		images[i] = &Image{}
+		if err := binary.Read(gz, binary.BigEndian, images[i]); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// BenchmarkReadImages parses a gzipped file of 60000 images, the size of the
+// MNIST training set, with the old per-image binary.Read parser and with
+// ReadImages, which reads them in bulk into one buffer.
+func BenchmarkReadImages(b *testing.B) {
+	images, _ := mnistFiles(b, 60000)
+	file := gzipped(b, images)
+	parsers := []struct {
+		name  string
+		parse func(io.Reader) ([]*Image, error)
+	}{
+		{"binary.Read", legacyReadImages},
+		{"bulk", ReadImages},
+	}
+	for _, parser := range parsers {
+		b.Run(parser.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(images)))
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.parse(bytes.NewReader(file)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLoadSets loads a training set of 60000 and a test set of 10000
+// gzipped images and labels from disk, the four files one by one and
+// concurrently.
+func BenchmarkLoadSets(b *testing.B) {
+	dir := b.TempDir()
+	names := [][2]string{
+		{filepath.Join(dir, TrainingImageFileName), filepath.Join(dir, TrainingLabelFileName)},
+		{filepath.Join(dir, TestImageFileName), filepath.Join(dir, TestLabelFileName)},
+	}
+	for i, n := range []int{60000, 10000} {
+		images, labels := mnistFiles(b, n)
+		for j, file := range [][]byte{images, labels} {
+			if err := os.WriteFile(names[i][j], gzipped(b, file), 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	for _, concurrent := range []bool{false, true} {
+		name := "one by one"
+		if concurrent {
+			name = "concurrently"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := LoadSets(concurrent, names...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// an Activation is the non-linearity a layer applies to its pre-activations z
+// activations have no state, so one value can be shared by any number of layers and networks
+type Activation interface {
+	// the name NewActivation turns back into this activation, e.g. "relu" or "leakyrelu:0.2"
+	Name() string
+	// a = f(z); a must already have the shape of z and may be z itself
+	Forward(z *Matrix, a *Matrix)
+	// turns the gradient of the loss with respect to a = f(z) into the gradient with respect to z, in place
+	// z and a are the values Forward was given and produced
+	Backward(z *Matrix, a *Matrix, grad *Matrix)
+}
+
+// the activations that can be picked by name from Config / the CLI
+// leakyrelu and elu take an optional slope / scale for negative inputs: "leakyrelu:0.2", "elu:0.5"
+var ActivationNames = []string{"relu", "leakyrelu", "elu", "gelu", "sigmoid", "tanh", "softmax"}
+
+// default slopes / scales of leakyrelu and elu
+const (
+	defaultLeakyReLUAlpha = 0.01
+	defaultELUAlpha       = 1.0
+)
+
+// returns the activation with the given name; "" means relu
+func NewActivation(name string) (Activation, error) {
+	base, parameter := name, ""
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		base, parameter = name[:i], name[i+1:]
+	}
+	alpha := math.NaN()
+	if parameter != "" {
+		value, err := strconv.ParseFloat(parameter, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, fmt.Errorf("invalid parameter in activation %q", name)
+		}
+		alpha = value
+	}
+
+	switch base {
+	case "relu", "":
+		if parameter == "" {
+			return relu{}, nil
+		}
+	case "leakyrelu":
+		if parameter == "" {
+			alpha = defaultLeakyReLUAlpha
+		}
+		return leakyReLU{alpha}, nil
+	case "elu":
+		if parameter == "" {
+			alpha = defaultELUAlpha
+		}
+		return elu{alpha}, nil
+	case "gelu":
+		if parameter == "" {
+			return gelu{}, nil
+		}
+	case "sigmoid":
+		if parameter == "" {
+			return sigmoid{}, nil
+		}
+	case "tanh":
+		if parameter == "" {
+			return tanh{}, nil
+		}
+	case "softmax":
+		if parameter == "" {
+			return softmax{}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown activation %q (want one of %v)", name, ActivationNames)
+	}
+	return nil, fmt.Errorf("activation %q takes no parameter", name)
+}
+
+// the activations of the hidden layers of a network with the given number of them
+// names holds one name per hidden layer, or a single name used for all of them; nil means relu everywhere
+func NewActivations(names []string, hidden int) ([]Activation, error) {
+	if len(names) > 1 && len(names) != hidden {
+		return nil, fmt.Errorf("%d activations given for %d hidden layers", len(names), hidden)
+	}
+	activations := make([]Activation, hidden)
+	for l := range activations {
+		name := ""
+		if len(names) == 1 {
+			name = names[0]
+		} else if len(names) > 1 {
+			name = names[l]
+		}
+		activation, err := NewActivation(name)
+		if err != nil {
+			return nil, err
+		}
+		activations[l] = activation
+	}
+	return activations, nil
+}
+
+// applies f to every element of z and writes it to a
+func forwardElements(op string, z *Matrix, a *Matrix, f func(float64) float64) {
+	checkShape(op, z, a)
+	for i := 0; i < z.Rows; i++ {
+		row, zRow := a.Row(i), z.Row(i)
+		for j, v := range zRow {
+			row[j] = f(v)
+		}
+	}
+}
+
+// multiplies every element of grad by f'(z), given z and a = f(z)
+func backwardElements(op string, z *Matrix, a *Matrix, grad *Matrix, derivative func(z, a float64) float64) {
+	checkShape(op, z, a)
+	checkShape(op, z, grad)
+	for i := 0; i < z.Rows; i++ {
+		row, zRow, aRow := grad.Row(i), z.Row(i), a.Row(i)
+		for j, v := range zRow {
+			row[j] *= derivative(v, aRow[j])
+		}
+	}
+}
+
+// max(0, z)
+// the derivative at 0 is taken to be 0
+type relu struct{}
+
+func (relu) Name() string { return "relu" }
+
+func (relu) Forward(z *Matrix, a *Matrix) {
+	z.ReLUInto(a)
+}
+
+func (relu) Backward(z *Matrix, a *Matrix, grad *Matrix) {
+	backwardElements("relu", z, a, grad, func(z, a float64) float64 {
+		if z > 0 {
+			return 1
+		}
+		return 0
+	})
+}
+
+// z for z > 0, alpha * z otherwise
+type leakyReLU struct {
+	alpha float64
+}
+
+func (f leakyReLU) Name() string {
+	if f.alpha == defaultLeakyReLUAlpha {
+		return "leakyrelu"
+	}
+	return "leakyrelu:" + strconv.FormatFloat(f.alpha, 'g', -1, 64)
+}
+
+func (f leakyReLU) Forward(z *Matrix, a *Matrix) {
+	forwardElements("leakyrelu", z, a, func(z float64) float64 {
+		if z > 0 {
+			return z
+		}
+		return f.alpha * z
+	})
+}
+
+func (f leakyReLU) Backward(z *Matrix, a *Matrix, grad *Matrix) {
+	backwardElements("leakyrelu", z, a, grad, func(z, a float64) float64 {
+		if z > 0 {
+			return 1
+		}
+		return f.alpha
+	})
+}
+
+// z for z > 0, alpha * (e^z - 1) otherwise
+type elu struct {
+	alpha float64
+}
+
+func (f elu) Name() string {
+	if f.alpha == defaultELUAlpha {
+		return "elu"
+	}
+	return "elu:" + strconv.FormatFloat(f.alpha, 'g', -1, 64)
+}
+
+func (f elu) Forward(z *Matrix, a *Matrix) {
+	forwardElements("elu", z, a, func(z float64) float64 {
+		if z > 0 {
+			return z
+		}
+		return f.alpha * math.Expm1(z)
+	})
+}
+
+// for z <= 0 the derivative alpha * e^z is a + alpha
+func (f elu) Backward(z *Matrix, a *Matrix, grad *Matrix) {
+	backwardElements("elu", z, a, grad, func(z, a float64) float64 {
+		if z > 0 {
+			return 1
+		}
+		return a + f.alpha
+	})
+}
+
+// z * Phi(z), where Phi is the standard normal CDF (the exact GELU, not the tanh approximation)
+type gelu struct{}
+
+func (gelu) Name() string { return "gelu" }
+
+func (gelu) Forward(z *Matrix, a *Matrix) {
+	forwardElements("gelu", z, a, func(z float64) float64 {
+		return z * normalCDF(z)
+	})
+}
+
+// d/dz z * Phi(z) = Phi(z) + z * phi(z)
+func (gelu) Backward(z *Matrix, a *Matrix, grad *Matrix) {
+	backwardElements("gelu", z, a, grad, func(z, a float64) float64 {
+		return normalCDF(z) + z*math.Exp(-z*z/2)/math.Sqrt(2*math.Pi)
+	})
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// 1 / (1 + e^-z)
+type sigmoid struct{}
+
+func (sigmoid) Name() string { return "sigmoid" }
+
+// e^-z is only ever taken of a non-positive number, so it can't overflow
+func (sigmoid) Forward(z *Matrix, a *Matrix) {
+	forwardElements("sigmoid", z, a, func(z float64) float64 {
+		if z >= 0 {
+			return 1 / (1 + math.Exp(-z))
+		}
+		e := math.Exp(z)
+		return e / (1 + e)
+	})
+}
+
+func (sigmoid) Backward(z *Matrix, a *Matrix, grad *Matrix) {
+	backwardElements("sigmoid", z, a, grad, func(z, a float64) float64 {
+		return a * (1 - a)
+	})
+}
+
+type tanh struct{}
+
+func (tanh) Name() string { return "tanh" }
+
+func (tanh) Forward(z *Matrix, a *Matrix) {
+	forwardElements("tanh", z, a, math.Tanh)
+}
+
+func (tanh) Backward(z *Matrix, a *Matrix, grad *Matrix) {
+	backwardElements("tanh", z, a, grad, func(z, a float64) float64 {
+		return 1 - a*a
+	})
+}
+
+// softmax over every column; the output layer always uses it
+// unlike the others it isn't element-wise: every output depends on the whole column
+type softmax struct{}
+
+func (softmax) Name() string { return "softmax" }
+
+func (softmax) Forward(z *Matrix, a *Matrix) {
+	z.SoftmaxInto(a)
+}
+
+// multiplies grad by the Jacobian diag(a) - a a^T column by column: g_i = a_i * (g_i - sum_k a_k g_k)
+func (softmax) Backward(z *Matrix, a *Matrix, grad *Matrix) {
+	checkShape("softmax", z, a)
+	checkShape("softmax", z, grad)
+	for j := 0; j < a.Cols; j++ {
+		dot := 0.0
+		for i := 0; i < a.Rows; i++ {
+			dot += a.Data[i*a.Stride+j] * grad.Data[i*grad.Stride+j]
+		}
+		for i := 0; i < a.Rows; i++ {
+			grad.Data[i*grad.Stride+j] = a.Data[i*a.Stride+j] * (grad.Data[i*grad.Stride+j] - dot)
+		}
+	}
+}
+
+// the names of a list of activations, as NewActivations takes them
+func activationNames(activations []Activation) []string {
+	names := make([]string, len(activations))
+	for i, activation := range activations {
+		names[i] = activation.Name()
+	}
+	return names
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// central differences with step h are accurate to about h^2 times the third derivative of the loss
+const (
+	finiteDifferenceStep = 1e-5
+	gradientTolerance    = 1e-6
+)
+
+// back propagation through a small network whose hidden layers all use one activation must match
+// the central difference (L(p + h) - L(p - h)) / 2h of every parameter p
+// inputs are spread enough that the pre-activations are rarely within h of ReLU's kink
+func TestActivationGradients(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, name := range append(ActivationNames, "leakyrelu:0.2", "elu:0.5") {
+		net := NewNetwork([]int{6, 5, 4, 3}, UniformInit, rng)
+		activations, err := NewActivations([]string{name}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		net.Activations = activations
+		x := NewMatrix(6, 8)
+		for i := range x.Data {
+			x.Data[i] = rng.NormFloat64()
+		}
+		y := make([]float64, x.Cols)
+		for j := range y {
+			y[j] = float64(rng.Intn(3))
+		}
+
+		zs, as := net.Forward(x)
+		dw, db := net.Backward(zs, as, y)
+		diff := 0.0
+		for l := range net.Weights {
+			for _, pair := range [][2]*Matrix{{net.Weights[l], dw[l]}, {net.Biases[l], db[l]}} {
+				params, grads := pair[0], pair[1]
+				for i, p := range params.Data {
+					params.Data[i] = p + finiteDifferenceStep
+					plus := net.Loss(x, y)
+					params.Data[i] = p - finiteDifferenceStep
+					minus := net.Loss(x, y)
+					params.Data[i] = p
+					numeric := (plus - minus) / (2 * finiteDifferenceStep)
+					diff = math.Max(diff, math.Abs(numeric-grads.Data[i]))
+				}
+			}
+		}
+		if !(diff < gradientTolerance) {
+			t.Errorf("%s: back propagation differs from finite differences by %.3g", name, diff)
+		}
+	}
+}
+
+func TestNewActivationNames(t *testing.T) {
+	for _, name := range []string{"relu", "leakyrelu:0.2", "elu:0.5", "gelu", "sigmoid", "tanh", "softmax"} {
+		activation, err := NewActivation(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if activation.Name() != name {
+			t.Errorf("%s is named %q", name, activation.Name())
+		}
+	}
+	for _, name := range []string{"swish", "relu:2", "leakyrelu:x", "elu:NaN", "elu:Inf"} {
+		if _, err := NewActivation(name); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+	if _, err := NewActivations([]string{"relu", "tanh"}, 3); err == nil {
+		t.Error("2 activations for 3 hidden layers accepted")
+	}
+}
@@ -0,0 +1,252 @@
+package scheduler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// model checkpoints are stored big-endian, like the MNIST files:
+//
+//	magic      uint32  "NNMC"
+//	version    uint16
+//	dtype      uint8   1 = float64
+//	reserved   uint8
+//	numSizes   uint32  number of layer sizes (weight layers + 1)
+//	sizes      numSizes x uint32
+//	then (since version 2) the activation of every hidden layer: its name's length as a uint8, then the name (see Activation.Name)
+//	then for every layer l: the Sizes[l+1]xSizes[l] weights row by row, followed by the Sizes[l+1] biases
+//
+// values are written as their raw IEEE 754 bits so a save/load round trip is bit-exact
+// version 1 files have no activations; their hidden layers are all ReLU
+
+var (
+	// ErrModelFormat indicates that the file is not a model checkpoint or is corrupt.
+	ErrModelFormat = errors.New("checkpoint: invalid format")
+
+	// ErrModelVersion indicates a checkpoint written by a newer, unknown version of the format.
+	ErrModelVersion = errors.New("checkpoint: unsupported version")
+)
+
+const (
+	modelMagic   = 0x4E4E4D43 // "NNMC"
+	modelVersion = 2
+
+	dtypeFloat64 = 1
+
+	// guards against allocating absurd amounts of memory for a corrupt header
+	// the parameters themselves are also only allocated as they are read (see readMatrix)
+	maxModelLayers     = 1 << 10
+	maxModelLayerSize  = 1 << 20
+	maxModelParameters = 1 << 28 // 2GB of float64s
+
+	// values read (and decoded) at a time
+	modelReadChunk = 1 << 12
+)
+
+type modelFileHeader struct {
+	Magic    uint32
+	Version  uint16
+	Dtype    uint8
+	Reserved uint8
+	NumSizes uint32
+}
+
+// writes the network to the file at path, replacing it if it exists
+func SaveModel(path string, net *Network) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteModel(file, net); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// reads a network from the checkpoint file at path
+func LoadModel(path string) (*Network, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadModel(file)
+}
+
+// writes the network to w in the checkpoint format
+func WriteModel(w io.Writer, net *Network) error {
+	writer := bufio.NewWriter(w)
+
+	header := modelFileHeader{
+		Magic:    modelMagic,
+		Version:  modelVersion,
+		Dtype:    dtypeFloat64,
+		NumSizes: uint32(len(net.Sizes)),
+	}
+	if err := binary.Write(writer, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	for _, size := range net.Sizes {
+		if err := binary.Write(writer, binary.BigEndian, uint32(size)); err != nil {
+			return err
+		}
+	}
+
+	for _, activation := range net.Activations {
+		name := activation.Name()
+		if len(name) > math.MaxUint8 {
+			return fmt.Errorf("checkpoint: activation name %q is too long", name)
+		}
+		if err := writer.WriteByte(uint8(len(name))); err != nil {
+			return err
+		}
+		if _, err := writer.WriteString(name); err != nil {
+			return err
+		}
+	}
+
+	for l := range net.Weights {
+		if err := writeMatrix(writer, net.Weights[l]); err != nil {
+			return err
+		}
+		if err := writeMatrix(writer, net.Biases[l]); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// reads a network in the checkpoint format from r
+func ReadModel(r io.Reader) (*Network, error) {
+	reader := bufio.NewReader(r)
+
+	header := modelFileHeader{}
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if header.Magic != modelMagic {
+		return nil, ErrModelFormat
+	}
+	if header.Version < 1 || header.Version > modelVersion {
+		return nil, fmt.Errorf("%w: %d", ErrModelVersion, header.Version)
+	}
+	if header.Dtype != dtypeFloat64 {
+		return nil, fmt.Errorf("%w: unknown dtype %d", ErrModelFormat, header.Dtype)
+	}
+	if header.NumSizes < 2 || header.NumSizes > maxModelLayers {
+		return nil, fmt.Errorf("%w: %d layer sizes", ErrModelFormat, header.NumSizes)
+	}
+
+	sizes := make([]int, header.NumSizes)
+	for i := range sizes {
+		var size uint32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if size == 0 || size > maxModelLayerSize {
+			return nil, fmt.Errorf("%w: layer size %d", ErrModelFormat, size)
+		}
+		sizes[i] = int(size)
+	}
+
+	parameters := 0
+	for l := 0; l+1 < len(sizes); l++ {
+		parameters += sizes[l+1]*sizes[l] + sizes[l+1]
+		if parameters > maxModelParameters {
+			return nil, fmt.Errorf("%w: layer sizes %v have more than %d parameters", ErrModelFormat, sizes, maxModelParameters)
+		}
+	}
+
+	layers := len(sizes) - 1
+	net := &Network{
+		Sizes:       sizes,
+		Weights:     make([]*Matrix, layers),
+		Biases:      make([]*Matrix, layers),
+		Activations: make([]Activation, layers-1),
+	}
+	for l := range net.Activations {
+		if header.Version < 2 {
+			net.Activations[l] = relu{}
+			continue
+		}
+		var err error
+		if net.Activations[l], err = readActivation(reader); err != nil {
+			return nil, err
+		}
+	}
+	for l := 0; l < layers; l++ {
+		var err error
+		if net.Weights[l], err = readMatrix(reader, sizes[l+1], sizes[l]); err != nil {
+			return nil, err
+		}
+		if net.Biases[l], err = readMatrix(reader, sizes[l+1], 1); err != nil {
+			return nil, err
+		}
+	}
+	return net, nil
+}
+
+// reads an activation name written by WriteModel
+func readActivation(r *bufio.Reader) (Activation, error) {
+	length, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	name := make([]byte, length)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	activation, err := NewActivation(string(name))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrModelFormat, err)
+	}
+	return activation, nil
+}
+
+// writes a matrix row by row
+func writeMatrix(w io.Writer, a *Matrix) error {
+	buf := make([]byte, 8)
+	for i := 0; i < a.Rows; i++ {
+		for _, v := range a.Row(i) {
+			binary.BigEndian.PutUint64(buf, math.Float64bits(v))
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reads a rows x cols matrix written by writeMatrix
+// a file that ends early is reported as io.ErrUnexpectedEOF
+// the values are read modelReadChunk at a time and appended, so a corrupt header that promises far more
+// parameters than the file holds can't make us allocate much more than the file's size
+func readMatrix(r io.Reader, rows, cols int) (*Matrix, error) {
+	n := rows * cols
+	data := make([]float64, 0, min(n, modelReadChunk))
+	buf := make([]byte, 8*min(n, modelReadChunk))
+	for len(data) < n {
+		chunk := buf[:8*min(n-len(data), modelReadChunk)]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		for k := 0; k < len(chunk); k += 8 {
+			data = append(data, math.Float64frombits(binary.BigEndian.Uint64(chunk[k:])))
+		}
+	}
+	return &Matrix{Rows: rows, Cols: cols, Stride: cols, Data: data}, nil
+}
+
+// a checkpoint that ends in the middle is truncated, not empty
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// a small network whose parameters include every special value a float64 can hold
+func specialNetwork() *Network {
+	net := NewNetwork([]int{5, 4, 3, 2}, HeInit, rand.New(rand.NewSource(1)))
+	net.Activations = []Activation{gelu{}, leakyReLU{alpha: 0.05}}
+	special := []float64{
+		math.NaN(),
+		math.Float64frombits(0x7FF8_0000_DEAD_BEEF), // NaN with a payload
+		math.Float64frombits(0xFFF0_0000_0000_0001), // negative signalling NaN
+		math.Inf(1), math.Inf(-1),
+		math.Copysign(0, -1),
+		math.SmallestNonzeroFloat64, -math.MaxFloat64,
+	}
+	for k, v := range special {
+		net.Weights[k%net.Layers()].Data[k] = v
+	}
+	net.Biases[1].Data[0] = math.Copysign(0, -1)
+	return net
+}
+
+func sameBitsMatrix(a, b *Matrix) bool {
+	if a.Rows != b.Rows || a.Cols != b.Cols {
+		return false
+	}
+	for i := 0; i < a.Rows; i++ {
+		for j := 0; j < a.Cols; j++ {
+			if math.Float64bits(a.At(i, j)) != math.Float64bits(b.At(i, j)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func checkSameNetwork(t *testing.T, got, want *Network) {
+	t.Helper()
+	if !sameSizes(got.Sizes, want.Sizes) {
+		t.Fatalf("sizes %v, want %v", got.Sizes, want.Sizes)
+	}
+	for l := range want.Weights {
+		if !sameBitsMatrix(got.Weights[l], want.Weights[l]) {
+			t.Errorf("layer %d weights differ", l)
+		}
+		if !sameBitsMatrix(got.Biases[l], want.Biases[l]) {
+			t.Errorf("layer %d biases differ", l)
+		}
+	}
+	if names, wantNames := activationNames(got.Activations), activationNames(want.Activations); len(names) != len(wantNames) {
+		t.Errorf("activations %v, want %v", names, wantNames)
+	} else {
+		for l := range names {
+			if names[l] != wantNames[l] {
+				t.Errorf("activations %v, want %v", names, wantNames)
+				break
+			}
+		}
+	}
+}
+
+func encodeModel(t *testing.T, net *Network) []byte {
+	t.Helper()
+	var file bytes.Buffer
+	if err := WriteModel(&file, net); err != nil {
+		t.Fatal(err)
+	}
+	return file.Bytes()
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	net := specialNetwork()
+	loaded, err := ReadModel(bytes.NewReader(encodeModel(t, net)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameNetwork(t, loaded, net)
+}
+
+// a version 1 file has no activations; its hidden layers are ReLU
+func TestCheckpointVersion1(t *testing.T) {
+	net := specialNetwork()
+	net.Activations = []Activation{relu{}, relu{}}
+
+	var file bytes.Buffer
+	binary.Write(&file, binary.BigEndian, modelFileHeader{Magic: modelMagic, Version: 1, Dtype: dtypeFloat64, NumSizes: uint32(len(net.Sizes))})
+	for _, size := range net.Sizes {
+		binary.Write(&file, binary.BigEndian, uint32(size))
+	}
+	for l := range net.Weights {
+		writeMatrix(&file, net.Weights[l])
+		writeMatrix(&file, net.Biases[l])
+	}
+
+	loaded, err := ReadModel(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameNetwork(t, loaded, net)
+}
+
+func TestCheckpointRejectsBadHeaders(t *testing.T) {
+	valid := encodeModel(t, specialNetwork())
+	cases := []struct {
+		name   string
+		offset int // where the header field starts
+		value  []byte
+		want   error
+	}{
+		{"magic", 0, []byte("MNCN"), ErrModelFormat},
+		{"version 0", 4, []byte{0, 0}, ErrModelVersion},
+		{"newer version", 4, []byte{0, modelVersion + 1}, ErrModelVersion},
+		{"dtype", 6, []byte{2}, ErrModelFormat},
+		{"one size", 8, []byte{0, 0, 0, 1}, ErrModelFormat},
+		{"too many sizes", 8, []byte{0, 0, 0x10, 0}, ErrModelFormat},
+		{"zero size", 12, []byte{0, 0, 0, 0}, ErrModelFormat},
+		{"huge size", 12, []byte{0, 0x20, 0, 0}, ErrModelFormat},
+		{"activation", 29, []byte("nope"), ErrModelFormat},
+	}
+	for _, c := range cases {
+		file := append([]byte{}, valid...)
+		copy(file[c.offset:], c.value)
+		if _, err := ReadModel(bytes.NewReader(file)); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestCheckpointTruncated(t *testing.T) {
+	file := encodeModel(t, specialNetwork())
+	for n := 0; n < len(file); n++ {
+		if _, err := ReadModel(bytes.NewReader(file[:n])); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("truncated to %d of %d bytes: got %v, want io.ErrUnexpectedEOF", n, len(file), err)
+		}
+	}
+}
+
+// a version 1 header with the given layer sizes and no parameters after it
+func hugeHeader(sizes ...uint32) []byte {
+	var file bytes.Buffer
+	binary.Write(&file, binary.BigEndian, modelFileHeader{Magic: modelMagic, Version: 1, Dtype: dtypeFloat64, NumSizes: uint32(len(sizes))})
+	binary.Write(&file, binary.BigEndian, sizes)
+	return file.Bytes()
+}
+
+func TestCheckpointCorruptHeaderAllocations(t *testing.T) {
+	// 2^40 parameters: rejected before reading any
+	if _, err := ReadModel(bytes.NewReader(hugeHeader(maxModelLayerSize, maxModelLayerSize))); !errors.Is(err, ErrModelFormat) {
+		t.Errorf("2^20 x 2^20 layer: got %v, want ErrModelFormat", err)
+	}
+
+	// 2^27 parameters (1GB) are under the cap, but the file ends after the header
+	file := hugeHeader(1<<14, 1<<13)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ReadModel(bytes.NewReader(file))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("header only: got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("a %d byte file made ReadModel allocate %d bytes", len(file), allocated)
+	}
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// how runParallel splits the training set between the members of the ensemble
+// every chunk is a contiguous range of samples, handed out as a view (Matrix.ColumnView) of the training matrix, never a copy
+
+// the chunk size used when neither Config.Chunks nor Config.ChunkSize is set (60 chunks on MNIST)
+const DefaultChunkSize = 1000
+
+// returns the [lo, hi) sample range of every chunk
+// chunks only:     the samples are split into that many chunks whose sizes differ by at most one
+// chunkSize only:  as many chunks of chunkSize as fit, plus a smaller final chunk with whatever is left over
+// both:            chunks chunks of chunkSize, taken from the start of the data; the rest is not used
+// neither:         the same as chunkSize = DefaultChunkSize
+func ChunkBounds(samples int, chunks int, chunkSize int) ([][2]int, error) {
+	if chunks < 0 || chunkSize < 0 {
+		return nil, fmt.Errorf("chunk count and size can't be negative (got %d and %d)", chunks, chunkSize)
+	}
+	if samples == 0 {
+		return nil, fmt.Errorf("no training samples to split into chunks")
+	}
+	if chunks == 0 && chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	var bounds [][2]int
+	switch {
+	case chunkSize == 0:
+		if chunks > samples {
+			return nil, fmt.Errorf("can't split %d samples into %d chunks", samples, chunks)
+		}
+		for i := 0; i < chunks; i++ {
+			bounds = append(bounds, [2]int{i * samples / chunks, (i + 1) * samples / chunks})
+		}
+	case chunks == 0:
+		for lo := 0; lo < samples; lo += chunkSize {
+			hi := lo + chunkSize
+			if hi > samples {
+				hi = samples // uneven final chunk
+			}
+			bounds = append(bounds, [2]int{lo, hi})
+		}
+	default:
+		if chunks*chunkSize > samples {
+			return nil, fmt.Errorf("%d chunks of %d samples need %d samples, only have %d", chunks, chunkSize, chunks*chunkSize, samples)
+		}
+		for i := 0; i < chunks; i++ {
+			bounds = append(bounds, [2]int{i * chunkSize, (i + 1) * chunkSize})
+		}
+	}
+	return bounds, nil
+}
+
+// splits x (features x samples) and y into chunks without copying: every chunk shares memory with x and y
+func SplitChunks(x *Matrix, y []float64, bounds [][2]int) ([]*Matrix, [][]float64) {
+	xChunks := make([]*Matrix, len(bounds))
+	yChunks := make([][]float64, len(bounds))
+	for i, bound := range bounds {
+		xChunks[i] = x.ColumnView(bound[0], bound[1])
+		yChunks[i] = y[bound[0]:bound[1]]
+	}
+	return xChunks, yChunks
+}
+
+// returns the sample order that makes every contiguous range of samples hold the classes
+// in (close to) the same proportions as the whole data set
+// the i-th of the n samples of a class gets the key (i + 0.5) / n and the samples are sorted by key,
+// so each class is spread evenly over the whole order; samples of a class keep their original order
+func StratifiedOrder(y []float64) []int {
+	counts := make(map[int]int)
+	for _, label := range y {
+		counts[int(label)]++
+	}
+	seen := make(map[int]int)
+	keys := make([]float64, len(y))
+	order := make([]int, len(y))
+	for j, label := range y {
+		class := int(label)
+		keys[j] = (float64(seen[class]) + 0.5) / float64(counts[class])
+		seen[class]++
+		order[j] = j
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if keys[order[a]] != keys[order[b]] {
+			return keys[order[a]] < keys[order[b]]
+		}
+		return y[order[a]] < y[order[b]]
+	})
+	return order
+}
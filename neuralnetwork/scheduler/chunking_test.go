@@ -0,0 +1,161 @@
+package scheduler
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestChunkBounds(t *testing.T) {
+	cases := []struct {
+		name                       string
+		samples, chunks, chunkSize int
+		want                       [][2]int
+	}{
+		{"default chunk size", 3000, 0, 0, [][2]int{{0, 1000}, {1000, 2000}, {2000, 3000}}},
+		{"default chunk size, uneven final chunk", 2500, 0, 0, [][2]int{{0, 1000}, {1000, 2000}, {2000, 2500}}},
+		{"chunk size only, uneven final chunk", 10, 0, 4, [][2]int{{0, 4}, {4, 8}, {8, 10}}},
+		{"chunk size larger than the data", 10, 0, 40, [][2]int{{0, 10}}},
+		{"chunks only", 10, 3, 0, [][2]int{{0, 3}, {3, 6}, {6, 10}}},
+		{"chunks only, one sample each", 4, 4, 0, [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}}},
+		{"both, rest unused", 10, 2, 3, [][2]int{{0, 3}, {3, 6}}},
+		{"both, exact fit", 9, 3, 3, [][2]int{{0, 3}, {3, 6}, {6, 9}}},
+	}
+	for _, c := range cases {
+		bounds, err := ChunkBounds(c.samples, c.chunks, c.chunkSize)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(bounds, c.want) {
+			t.Errorf("%s: ChunkBounds(%d, %d, %d) = %v, want %v", c.name, c.samples, c.chunks, c.chunkSize, bounds, c.want)
+		}
+	}
+}
+
+// with only a chunk count, the chunks must cover every sample once and differ in size by at most one
+func TestChunkBoundsChunksOnly(t *testing.T) {
+	for samples := 1; samples <= 40; samples++ {
+		for chunks := 1; chunks <= samples; chunks++ {
+			bounds, err := ChunkBounds(samples, chunks, 0)
+			if err != nil {
+				t.Fatalf("%d samples, %d chunks: %v", samples, chunks, err)
+			}
+			if len(bounds) != chunks {
+				t.Fatalf("%d samples, %d chunks: got %d chunks", samples, chunks, len(bounds))
+			}
+			lo := 0
+			smallest, largest := samples, 0
+			for _, bound := range bounds {
+				if bound[0] != lo {
+					t.Fatalf("%d samples, %d chunks: %v doesn't follow on from %d", samples, chunks, bounds, lo)
+				}
+				size := bound[1] - bound[0]
+				if size < smallest {
+					smallest = size
+				}
+				if size > largest {
+					largest = size
+				}
+				lo = bound[1]
+			}
+			if lo != samples || largest-smallest > 1 {
+				t.Fatalf("%d samples, %d chunks: uneven or incomplete split %v", samples, chunks, bounds)
+			}
+		}
+	}
+}
+
+func TestChunkBoundsRejects(t *testing.T) {
+	cases := []struct {
+		name                       string
+		samples, chunks, chunkSize int
+	}{
+		{"no samples", 0, 0, 0},
+		{"negative chunks", 10, -1, 0},
+		{"negative chunk size", 10, 0, -1},
+		{"more chunks than samples", 10, 11, 0},
+		{"chunks that don't fit", 10, 3, 4},
+	}
+	for _, c := range cases {
+		if bounds, err := ChunkBounds(c.samples, c.chunks, c.chunkSize); err == nil {
+			t.Errorf("%s: ChunkBounds(%d, %d, %d) = %v, want an error", c.name, c.samples, c.chunks, c.chunkSize, bounds)
+		}
+	}
+}
+
+// the chunks are views: writing through a chunk writes to the training data
+func TestSplitChunksShareMemory(t *testing.T) {
+	x := MatrixFromSlices([][]float64{{0, 1, 2, 3, 4}, {5, 6, 7, 8, 9}})
+	y := []float64{0, 1, 2, 3, 4}
+	xChunks, yChunks := SplitChunks(x, y, [][2]int{{0, 2}, {2, 5}})
+	if xChunks[1].Cols != 3 || xChunks[1].At(1, 0) != 7 || !reflect.DeepEqual(yChunks[1], []float64{2, 3, 4}) {
+		t.Fatalf("second chunk is %v, %v", xChunks[1].ToSlices(), yChunks[1])
+	}
+	xChunks[1].Set(0, 2, -1)
+	yChunks[1][2] = -1
+	if x.At(0, 4) != -1 || y[4] != -1 {
+		t.Error("chunks don't share memory with the training data")
+	}
+}
+
+// every chunk of a stratified order holds the classes in the same proportions as the whole set,
+// even when the set is sorted by class
+func TestStratifiedOrder(t *testing.T) {
+	cases := []struct {
+		name   string
+		counts []int // samples per class, stored class by class
+		chunks int
+	}{
+		{"balanced", []int{10, 10, 10}, 10},
+		{"imbalanced", []int{30, 20, 10}, 10},
+		{"imbalanced, uneven chunks", []int{25, 15, 5}, 4},
+		{"a class smaller than the chunk count", []int{20, 3}, 5},
+		{"MNIST's training set", []int{5923, 6742, 5958, 6131, 5842, 5421, 5918, 6265, 5851, 5949}, 60},
+	}
+	for _, c := range cases {
+		var y []float64
+		for class, count := range c.counts {
+			for i := 0; i < count; i++ {
+				y = append(y, float64(class))
+			}
+		}
+		order := StratifiedOrder(y)
+
+		// a permutation that keeps the samples of a class in their original order
+		seen := make([]bool, len(y))
+		last := make(map[float64]int)
+		for _, j := range order {
+			if seen[j] {
+				t.Fatalf("%s: sample %d appears twice in %v", c.name, j, order)
+			}
+			seen[j] = true
+			if prev, ok := last[y[j]]; ok && j < prev {
+				t.Fatalf("%s: class %v out of its original order in %v", c.name, y[j], order)
+			}
+			last[y[j]] = j
+		}
+		if len(order) != len(y) {
+			t.Fatalf("%s: order of %d samples for %d", c.name, len(order), len(y))
+		}
+
+		// every chunk holds each class within a sample and a half of its share of the chunk
+		// (rounding at both ends of a chunk can each cost up to about three quarters of a sample)
+		bounds, err := ChunkBounds(len(y), c.chunks, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, bound := range bounds {
+			counts := make([]int, len(c.counts))
+			for _, j := range order[bound[0]:bound[1]] {
+				counts[int(y[j])]++
+			}
+			for class, count := range counts {
+				share := float64(c.counts[class]) * float64(bound[1]-bound[0]) / float64(len(y))
+				if math.Abs(float64(count)-share) > 1.5 {
+					t.Errorf("%s: chunk %v holds %d of class %d, want %.1f", c.name, bound, count, class, share)
+				}
+			}
+		}
+	}
+}
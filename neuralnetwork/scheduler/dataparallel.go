@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"proj3/concurrent"
+)
+
+// synchronous data parallelism: unlike the ensemble modes there is only one network
+// every mini-batch is split into one shard per thread, the shards' gradients are computed in parallel,
+// summed with a tree reduction, and then the shared network takes a single optimizer step
+// the summed gradient is exactly the mini-batch gradient, so this trains the same model as runSequential
+
+// the gradients and loss of one shard, already weighted by the shard's share of the mini-batch
+type shardGradients struct {
+	dw   []*Matrix
+	db   []*Matrix
+	loss float64
+}
+
+// a GradientTask computes the gradients of the shared network on one shard of a mini-batch
+// it only reads the network, so all of a step's shards can run at the same time
+// every shard has its own workspace over the shared network, reused for every mini-batch
+type GradientTask struct {
+	workspace *Workspace
+	xShard    *Matrix
+	yShard    []float64
+	batchSize int // samples in the whole mini-batch
+}
+
+func NewGradientTask(workspace *Workspace, xShard *Matrix, yShard []float64, batchSize int) concurrent.Callable {
+	return &GradientTask{workspace, xShard, yShard, batchSize}
+}
+
+// returns *shardGradients, which live in the shard's workspace until its next mini-batch
+// the gradients are averaged over the shard, so we rescale by shard size / batch size
+// to make the sum over all shards the average over the whole mini-batch
+func (task *GradientTask) Call() interface{} {
+	dw, db, loss := task.workspace.Gradients(task.xShard, task.yShard)
+	share := float64(len(task.yShard)) / float64(task.batchSize)
+	for l := range dw {
+		dw[l].ScalarMultiplyInto(share, dw[l])
+		db[l].ScalarMultiplyInto(share, db[l])
+	}
+	return &shardGradients{dw, db, loss * share}
+}
+
+// a ReduceTask adds one shard's gradients into another's
+type ReduceTask struct {
+	into *shardGradients
+	from *shardGradients
+}
+
+func NewReduceTask(into *shardGradients, from *shardGradients) concurrent.Runnable {
+	return &ReduceTask{into, from}
+}
+
+func (task *ReduceTask) Run() {
+	for l := range task.into.dw {
+		task.into.dw[l].AddInto(task.from.dw[l], task.into.dw[l])
+		task.into.db[l].AddInto(task.from.db[l], task.into.db[l])
+	}
+	task.into.loss += task.from.loss
+}
+
+// sums every shard's gradients into grads[0] and returns it
+// each round adds pairs that are stride apart in parallel, so it takes ceil(log2(shards)) rounds
+func treeReduce(executor concurrent.ExecutorService, grads []*shardGradients) *shardGradients {
+	for stride := 1; stride < len(grads); stride *= 2 {
+		var futures []concurrent.Future
+		for i := 0; i+stride < len(grads); i += 2 * stride {
+			futures = append(futures, executor.Submit(NewReduceTask(grads[i], grads[i+stride])))
+		}
+		for _, future := range futures {
+			future.Get() // the next round reads these sums
+		}
+	}
+	return grads[0]
+}
+
+// mini-batch gradient descent with each step's gradient computed by shards threads in parallel
+// trains net in place and returns it
+func DataParallelGradientDescent(executor concurrent.ExecutorService, shards int, net *Network, x *Matrix, y []float64, options TrainOptions) *Network {
+	workspaces := make([]*Workspace, shards)
+	for i := range workspaces {
+		workspaces[i] = NewWorkspace(net)
+	}
+	var batches batcher
+	for epoch := 0; epoch < options.Epochs; epoch++ {
+		total := 0.0
+		batches.epoch(x, y, options, func(xBatch *Matrix, yBatch []float64) {
+			total += dataParallelStep(executor, workspaces, net, xBatch, yBatch, options.Optimizer) * float64(len(yBatch))
+		})
+		if options.EpochLoss != nil {
+			options.EpochLoss(epoch, total/float64(len(y)))
+		}
+	}
+	return net
+}
+
+// one optimizer step of net on a mini-batch split between the shards' workspaces; returns the mini-batch's loss
+func dataParallelStep(executor concurrent.ExecutorService, workspaces []*Workspace, net *Network, xBatch *Matrix, yBatch []float64, optimizer Optimizer) float64 {
+	m := len(yBatch)
+	count := len(workspaces)
+	if count > m {
+		count = m // never hand out an empty shard
+	}
+	if count == 0 {
+		return 0 // no samples (or no shards), so there is no gradient to step along
+	}
+
+	// shard i gets columns [i*m/count, (i+1)*m/count), so shard sizes differ by at most one
+	futures := make([]concurrent.Future, count)
+	for i := 0; i < count; i++ {
+		lo, hi := i*m/count, (i+1)*m/count
+		futures[i] = executor.Submit(NewGradientTask(workspaces[i], xBatch.ColumnView(lo, hi), yBatch[lo:hi], m))
+	}
+	grads := make([]*shardGradients, count)
+	for i, future := range futures {
+		grads[i] = future.Get().(*shardGradients)
+	}
+
+	sum := treeReduce(executor, grads)
+	optimizer.Step(net, sum.dw, sum.db) // the only write to net, after every shard is done reading it
+	return sum.loss
+}
+
+// our data parallel version trains one network; ThreadCount is both the number of goroutines and the number of shards
+// config must have been completed and checked by Schedule
+func runDataParallel(config Config, data Dataset, start *Network) (*Network, *Report, error) {
+	xTrain, yTrain := data.Train().X, data.Train().Y
+	xTest, yTest := data.Test().X, data.Test().Y
+
+	var losses []float64
+	options, err := trainOptions(config, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	options = recordLoss(options, &losses)
+	network, err := startingNetwork(config, start, options.Rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	idle, err := idleOption(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	executor := concurrent.NewWorkStealingExecutor(config.ThreadCount, 10, idle)
+	network = DataParallelGradientDescent(executor, config.ThreadCount, network, xTrain, yTrain, options)
+	executor.Shutdown()
+
+	// generates accuracy, loss and per-class metrics for test data
+	report := Evaluate(network, xTest, yTest)
+	report.TrainingLoss = losses
+	report.Seed = config.Seed
+	return network, report, nil
+}
@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"proj3/mnist"
+)
+
+// a Dataset is a labelled data set, already loaded and split into training and test samples
+type Dataset interface {
+	Name() string
+	Features() int        // inputs per sample: the size of the network's input layer
+	Classes() int         // labels run from 0 to Classes() - 1: the size of the network's output layer
+	ClassNames() []string // what every label stands for, e.g. "7" or "Sneaker"
+	Train() Split
+	Test() Split
+}
+
+// one part of a data set: X holds one sample per column (features x samples) and Y their labels
+type Split struct {
+	X *Matrix
+	Y []float64
+}
+
+// number of samples in the split
+func (s Split) Samples() int {
+	return len(s.Y)
+}
+
+// where the data files are looked for when neither Config.DataDir nor $MNIST_DIR is set
+const DefaultDataDir = "../../proj3/mnist"
+
+// the environment variable that sets the data directory when Config.DataDir is empty
+const DataDirEnv = "MNIST_DIR"
+
+// the directory the data set is loaded from: Config.DataDir, then $MNIST_DIR, then DefaultDataDir
+func DataDir(config Config) string {
+	if config.DataDir != "" {
+		return config.DataDir
+	}
+	if dir := os.Getenv(DataDirEnv); dir != "" {
+		return dir
+	}
+	return DefaultDataDir
+}
+
+// loads the data set the config asks for; both of its splits must hold samples
+func LoadData(config Config) (Dataset, error) {
+	preset, err := FindPreset(config.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	dir := DataDir(config)
+	data, err := preset.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't load %s from %q (set the directory with -data or $%s): %w", preset.Name, dir, DataDirEnv, err)
+	}
+	// nothing can be trained on or evaluated over an empty split
+	if data.Train().Samples() == 0 || data.Test().Samples() == 0 {
+		return nil, fmt.Errorf("%s in %q has %d training and %d test samples: neither may be empty",
+			preset.Name, dir, data.Train().Samples(), data.Test().Samples())
+	}
+	return data, nil
+}
+
+// a data set of 28x28 grayscale images in the IDX format of the MNIST files
+// all of them are read with the mnist package; only the file names, the classes and the image layout differ
+type DatasetPreset struct {
+	Name        string
+	Files       [4]string // training images, training labels, test images, test labels
+	Classes     []string  // the name of every class, in label order
+	LabelOffset int       // the label of the first class in the files (EMNIST letters count from 1)
+	Transposed  bool      // images are stored column by column (EMNIST); they're turned upright when loaded
+}
+
+// the names of the presets Config.Dataset / the CLI can pick
+var DatasetNames = []string{"mnist", "fashion", "kmnist", "emnist-letters", "emnist-balanced"}
+
+// the file names the MNIST download uses; Fashion-MNIST and KMNIST use the same ones
+var mnistFiles = [4]string{
+	mnist.TrainingImageFileName, mnist.TrainingLabelFileName,
+	mnist.TestImageFileName, mnist.TestLabelFileName,
+}
+
+var (
+	digits  = []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	letters = []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M",
+		"N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z"}
+)
+
+// every data set LoadData can load, by name
+var DatasetPresets = map[string]*DatasetPreset{
+	// http://yann.lecun.com/exdb/mnist/
+	"mnist": {Name: "mnist", Files: mnistFiles, Classes: digits},
+	// https://github.com/zalandoresearch/fashion-mnist
+	"fashion": {Name: "fashion", Files: mnistFiles, Classes: []string{
+		"T-shirt/top", "Trouser", "Pullover", "Dress", "Coat", "Sandal", "Shirt", "Sneaker", "Bag", "Ankle boot"}},
+	// https://github.com/rois-codh/kmnist: 10 hiragana, named by their romanization
+	"kmnist": {Name: "kmnist", Files: mnistFiles, Classes: []string{
+		"o", "ki", "su", "tsu", "na", "ha", "ma", "ya", "re", "wo"}},
+	// https://www.nist.gov/itl/products-and-services/emnist-dataset, from gzip.zip
+	// letters merges upper and lower case into 26 classes labelled 1 to 26
+	"emnist-letters": {Name: "emnist-letters", Files: emnistFiles("letters"), Classes: letters, LabelOffset: 1, Transposed: true},
+	// balanced: the digits, the upper case letters and the 11 lower case letters that don't look like their upper case
+	"emnist-balanced": {Name: "emnist-balanced", Files: emnistFiles("balanced"), Classes: concat(digits, letters,
+		[]string{"a", "b", "d", "e", "f", "g", "h", "n", "q", "r", "t"}), Transposed: true},
+}
+
+func emnistFiles(split string) [4]string {
+	prefix := "emnist-" + split + "-"
+	return [4]string{
+		prefix + "train-images-idx3-ubyte.gz", prefix + "train-labels-idx1-ubyte.gz",
+		prefix + "test-images-idx3-ubyte.gz", prefix + "test-labels-idx1-ubyte.gz",
+	}
+}
+
+func concat(lists ...[]string) []string {
+	var res []string
+	for _, list := range lists {
+		res = append(res, list...)
+	}
+	return res
+}
+
+// returns the preset with the given name; "" means mnist
+func FindPreset(name string) (*DatasetPreset, error) {
+	if name == "" {
+		name = "mnist"
+	}
+	preset, ok := DatasetPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %q (want one of %v)", name, DatasetNames)
+	}
+	return preset, nil
+}
+
+// inputs per sample: one per pixel
+func (preset *DatasetPreset) Features() int {
+	return mnist.Width * mnist.Height
+}
+
+// loads the preset's four files from dir, each in its own goroutine
+func (preset *DatasetPreset) Load(dir string) (Dataset, error) {
+	sets, err := mnist.LoadSets(true,
+		[2]string{filepath.Join(dir, preset.Files[0]), filepath.Join(dir, preset.Files[1])},
+		[2]string{filepath.Join(dir, preset.Files[2]), filepath.Join(dir, preset.Files[3])})
+	if err != nil {
+		return nil, err
+	}
+	train, err := preset.split(sets[0], preset.Files[1])
+	if err != nil {
+		return nil, err
+	}
+	test, err := preset.split(sets[1], preset.Files[3])
+	if err != nil {
+		return nil, err
+	}
+	return &presetDataset{preset: preset, train: train, test: test}, nil
+}
+
+// each image is represented as a 784-byte array
+// we convert the images to the columns of a matrix and the labels to a vector of float64s
+func (preset *DatasetPreset) split(set *mnist.Set, labelFile string) (Split, error) {
+	if preset.Transposed {
+		for _, image := range set.Images {
+			transposeImage(image)
+		}
+	}
+
+	first := mnist.Label(preset.LabelOffset)
+	if err := mnist.CheckLabels(set.Labels, first, first+mnist.Label(len(preset.Classes)-1)); err != nil {
+		return Split{}, fmt.Errorf("%s: %w", labelFile, err)
+	}
+	y := LabelsToVector(set.Labels)
+	for j := range y {
+		y[j] -= float64(preset.LabelOffset)
+	}
+
+	x := ImagesToMatrix(set.Images)    // 784 x samples
+	x.ScalarMultiplyInto(1.0/255.0, x) // normalize the data
+	return Split{X: x, Y: y}, nil
+}
+
+// swaps the rows and columns of a square image in place
+func transposeImage(image *mnist.Image) {
+	for r := 0; r < mnist.Height; r++ {
+		for c := r + 1; c < mnist.Width; c++ {
+			image[r*mnist.Width+c], image[c*mnist.Width+r] = image[c*mnist.Width+r], image[r*mnist.Width+c]
+		}
+	}
+}
+
+// a loaded preset
+type presetDataset struct {
+	preset *DatasetPreset
+	train  Split
+	test   Split
+}
+
+func (data *presetDataset) Name() string         { return data.preset.Name }
+func (data *presetDataset) Features() int        { return data.preset.Features() }
+func (data *presetDataset) Classes() int         { return len(data.preset.Classes) }
+func (data *presetDataset) ClassNames() []string { return data.preset.Classes }
+func (data *presetDataset) Train() Split         { return data.train }
+func (data *presetDataset) Test() Split          { return data.test }
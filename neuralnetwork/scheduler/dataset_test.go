@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"proj3/idx"
+	"proj3/mnist"
+)
+
+func TestLoadData(t *testing.T) {
+	dir := t.TempDir()
+	writeMNIST(t, dir, 30, 20)
+	data, err := LoadData(Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Train().Samples() != 30 || data.Test().Samples() != 20 || data.Train().X.Rows != data.Features() {
+		t.Errorf("loaded %d training and %d test samples of %d features", data.Train().Samples(), data.Test().Samples(), data.Train().X.Rows)
+	}
+}
+
+// without Config.DataDir the directory comes from $MNIST_DIR
+func TestLoadDataFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeMNIST(t, dir, 30, 20)
+	t.Setenv(DataDirEnv, dir)
+	if got := DataDir(Config{}); got != dir {
+		t.Errorf("DataDir = %q, want $%s = %q", got, DataDirEnv, dir)
+	}
+	if got := DataDir(Config{DataDir: "elsewhere"}); got != "elsewhere" {
+		t.Errorf("DataDir = %q, want Config.DataDir to win over $%s", got, DataDirEnv)
+	}
+	if _, err := LoadData(Config{}); err != nil {
+		t.Error(err)
+	}
+
+	t.Setenv(DataDirEnv, "")
+	if got := DataDir(Config{}); got != DefaultDataDir {
+		t.Errorf("DataDir = %q, want the default %q", got, DefaultDataDir)
+	}
+}
+
+func TestLoadDataMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadData(Config{DataDir: dir})
+	if !errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), DataDirEnv) {
+		t.Errorf("got %v, want a missing file error that mentions $%s", err, DataDirEnv)
+	}
+}
+
+func TestLoadDataEmptySplit(t *testing.T) {
+	for _, samples := range [][2]int{{0, 20}, {30, 0}} {
+		dir := t.TempDir()
+		writeMNIST(t, dir, samples[0], samples[1])
+		if data, err := LoadData(Config{DataDir: dir}); err == nil {
+			t.Errorf("%d training and %d test samples: loaded %s, want an error", samples[0], samples[1], data.Name())
+		}
+	}
+}
+
+// writes images and labels as the named IDX files in dir, the way writeMNIST does
+func writeSet(t *testing.T, dir, imageName, labelName string, images []*mnist.Image, labels []uint8) {
+	t.Helper()
+	imageArray, err := idx.NewArray(idx.Ubyte, len(images), mnist.Height, mnist.Width)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixels := imageArray.Data.([]uint8)
+	for i, image := range images {
+		copy(pixels[i*len(image):], image[:])
+	}
+	labelArray, err := idx.NewArray(idx.Ubyte, len(labels))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(labelArray.Data.([]uint8), labels)
+	if err := idx.WriteFile(filepath.Join(dir, imageName), imageArray); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.WriteFile(filepath.Join(dir, labelName), labelArray); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPresetClasses(t *testing.T) {
+	want := map[string]int{"mnist": 10, "fashion": 10, "kmnist": 10, "emnist-letters": 26, "emnist-balanced": 47}
+	if len(DatasetPresets) != len(DatasetNames) {
+		t.Errorf("%d presets but %d names", len(DatasetPresets), len(DatasetNames))
+	}
+	for _, name := range DatasetNames {
+		preset, err := FindPreset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(preset.Classes) != want[name] {
+			t.Errorf("%s has %d classes, want %d", name, len(preset.Classes), want[name])
+		}
+	}
+}
+
+// EMNIST letters are labelled 1 to 26 and stored column by column:
+// loading shifts the labels to 0 to 25 and turns the images upright
+func TestLoadEMNISTLetters(t *testing.T) {
+	preset, err := FindPreset("emnist-letters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every image has one lit pixel, in row 0 and a column that depends on the image, as stored
+	images := make([]*mnist.Image, 26)
+	labels := make([]uint8, 26)
+	for i := range images {
+		images[i] = new(mnist.Image)
+		images[i][i] = 255
+		labels[i] = uint8(i + 1)
+	}
+	dir := t.TempDir()
+	writeSet(t, dir, preset.Files[0], preset.Files[1], images, labels)
+	writeSet(t, dir, preset.Files[2], preset.Files[3], images[:5], labels[:5])
+
+	data, err := LoadData(Config{Dataset: "emnist-letters", DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Classes() != 26 || data.ClassNames()[0] != "A" || data.Train().Samples() != 26 || data.Test().Samples() != 5 {
+		t.Fatalf("%d classes from %q, %d training and %d test samples",
+			data.Classes(), data.ClassNames()[0], data.Train().Samples(), data.Test().Samples())
+	}
+	train := data.Train()
+	for j, label := range train.Y {
+		if label != float64(j) {
+			t.Errorf("sample %d: label %v, want %d", j, label, j)
+		}
+		// stored at row 0, column j: upright it's at row j, column 0
+		for p := 0; p < train.X.Rows; p++ {
+			want := 0.0
+			if p == j*mnist.Width {
+				want = 1
+			}
+			if train.X.At(p, j) != want {
+				t.Fatalf("sample %d: pixel %d is %v, want %v", j, p, train.X.At(p, j), want)
+			}
+		}
+	}
+
+	// a label outside 1 to 26 is rejected
+	for _, bad := range []uint8{0, 27} {
+		labels[3] = bad
+		writeSet(t, dir, preset.Files[0], preset.Files[1], images, labels)
+		if _, err := LoadData(Config{Dataset: "emnist-letters", DataDir: dir}); !errors.Is(err, mnist.ErrLabel) {
+			t.Errorf("label %d: got %v, want mnist.ErrLabel", bad, err)
+		}
+	}
+}
+
+// MNIST's images aren't transposed and its labels aren't shifted
+func TestLoadMNISTUnchanged(t *testing.T) {
+	image := new(mnist.Image)
+	image[1] = 255 // row 0, column 1
+	dir := t.TempDir()
+	writeSet(t, dir, mnist.TrainingImageFileName, mnist.TrainingLabelFileName, []*mnist.Image{image}, []uint8{9})
+	writeSet(t, dir, mnist.TestImageFileName, mnist.TestLabelFileName, []*mnist.Image{image}, []uint8{0})
+	data, err := LoadData(Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if train := data.Train(); train.Y[0] != 9 || train.X.At(1, 0) != 1 || train.X.At(mnist.Width, 0) != 0 {
+		t.Errorf("label %v, pixel 1 %v, pixel %d %v", train.Y[0], train.X.At(1, 0), mnist.Width, train.X.At(mnist.Width, 0))
+	}
+}
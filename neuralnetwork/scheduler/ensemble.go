@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"proj3/concurrent"
+)
+
+// the ways the members of an ensemble can be combined into a single prediction
+// "weights" averages the parameters into one network (AggregateResults); the others run every member
+// and combine their softmax outputs by arithmetic mean, geometric mean, or majority vote
+var CombineNames = []string{"weights", "mean", "geomean", "vote"}
+
+// an Ensemble keeps every trained network instead of collapsing them into one
+type Ensemble struct {
+	Members []*Network
+}
+
+// the network with the averaged weights and biases of every member
+func (ensemble *Ensemble) Average() *Network {
+	return AggregateResults(ensemble.Members)
+}
+
+// an InferenceTask runs one member of an ensemble forward over the data
+type InferenceTask struct {
+	network *Network
+	x       *Matrix
+}
+
+func NewInferenceTask(network *Network, x *Matrix) concurrent.Callable {
+	return &InferenceTask{network, x}
+}
+
+// returns the member's softmax outputs (classes x m)
+func (task *InferenceTask) Call() interface{} {
+	_, as := task.network.Forward(task.x)
+	return as[len(as)-1]
+}
+
+// runs every member over x in parallel on the executor and returns their softmax outputs, in member order
+func (ensemble *Ensemble) Outputs(executor concurrent.ExecutorService, x *Matrix) []*Matrix {
+	futures := make([]concurrent.Future, len(ensemble.Members))
+	for i, member := range ensemble.Members {
+		futures[i] = executor.Submit(NewInferenceTask(member, x))
+	}
+	outputs := make([]*Matrix, len(futures))
+	for i, future := range futures {
+		outputs[i] = future.Get().(*Matrix)
+	}
+	return outputs
+}
+
+// combines the members' softmax outputs into one classes x m matrix of class probabilities
+// mean:    average probability
+// geomean: exp of the average log probability, renormalized so every column sums to 1
+// vote:    fraction of members whose top class it is
+// a tie, within a member's output or between classes with as many votes, goes to the lowest class index
+// (Argmax keeps the first maximum): two members voting 0 and 1 give class 0 and class 1 half each, and predict 0
+func CombineOutputs(outputs []*Matrix, method string) *Matrix {
+	classes, m := outputs[0].Rows, outputs[0].Cols
+	members := float64(len(outputs))
+	combined := NewMatrix(classes, m)
+
+	switch method {
+	case "mean":
+		for _, output := range outputs {
+			combined.AddInto(output, combined)
+		}
+		combined.ScalarMultiplyInto(1/members, combined)
+	case "geomean":
+		for _, output := range outputs {
+			for i := 0; i < classes; i++ {
+				row, combinedRow := output.Row(i), combined.Row(i)
+				for j, p := range row {
+					combinedRow[j] += math.Log(math.Max(p, minProbability))
+				}
+			}
+		}
+		for j := 0; j < m; j++ {
+			sum := 0.0
+			for i := 0; i < classes; i++ {
+				p := math.Exp(combined.At(i, j) / members)
+				combined.Set(i, j, p)
+				sum += p
+			}
+			for i := 0; i < classes; i++ {
+				combined.Set(i, j, combined.At(i, j)/sum)
+			}
+		}
+	case "vote":
+		for _, output := range outputs {
+			for j, class := range output.Argmax() {
+				combined.Set(int(class), j, combined.At(int(class), j)+1/members)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unknown combination method %q", method))
+	}
+	return combined
+}
+
+// evaluates the ensemble on a labelled data set using the given combination method
+func (ensemble *Ensemble) Evaluate(executor concurrent.ExecutorService, method string, x *Matrix, y []float64) *Report {
+	if method == "weights" || method == "" {
+		return Evaluate(ensemble.Average(), x, y)
+	}
+	return EvaluateProbabilities(CombineOutputs(ensemble.Outputs(executor, x), method), y)
+}
+
+// test accuracy of every combination method, for comparing weight averaging against output averaging
+// the members are only run once and their outputs reused for every output-combining method
+func (ensemble *Ensemble) Compare(executor concurrent.ExecutorService, x *Matrix, y []float64) map[string]float64 {
+	outputs := ensemble.Outputs(executor, x)
+	accuracies := make(map[string]float64, len(CombineNames))
+	for _, method := range CombineNames {
+		if method == "weights" {
+			accuracies[method] = GetAccuracy(MakePredictions(x, ensemble.Average()), y)
+		} else {
+			accuracies[method] = GetAccuracy(CombineOutputs(outputs, method).Argmax(), y)
+		}
+	}
+	return accuracies
+}
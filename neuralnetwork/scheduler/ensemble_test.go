@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"math"
+	"proj3/concurrent"
+	"reflect"
+	"testing"
+)
+
+func closeMatrix(a *Matrix, b [][]float64, tolerance float64) bool {
+	for i := range b {
+		for j, v := range b[i] {
+			if math.Abs(a.At(i, j)-v) > tolerance {
+				return false
+			}
+		}
+	}
+	return a.Rows == len(b) && a.Cols == len(b[0])
+}
+
+func TestCombineOutputs(t *testing.T) {
+	// two members, three classes, two samples; every column sums to 1
+	outputs := []*Matrix{
+		MatrixFromSlices([][]float64{{0.5, 0.2}, {0.3, 0.2}, {0.2, 0.6}}),
+		MatrixFromSlices([][]float64{{0.1, 0.4}, {0.6, 0.4}, {0.3, 0.2}}),
+	}
+	cases := []struct {
+		method string
+		want   [][]float64
+		argmax []float64
+	}{
+		{"mean", [][]float64{{0.3, 0.3}, {0.45, 0.3}, {0.25, 0.4}}, []float64{1, 2}},
+		// sqrt(0.05), sqrt(0.18), sqrt(0.06) sum to 0.8928; sqrt(0.08), sqrt(0.08), sqrt(0.12) to 0.9121
+		{"geomean", [][]float64{{0.2504501, 0.3101021}, {0.4751956, 0.3101021}, {0.2743543, 0.3797959}}, []float64{1, 2}},
+		// sample 0: the members vote 0 and 1; sample 1: 2 and 0, the second member's 0.4/0.4 tie going to class 0
+		// both samples tie between two classes, and the lower one wins
+		{"vote", [][]float64{{0.5, 0.5}, {0.5, 0}, {0, 0.5}}, []float64{0, 0}},
+	}
+	for _, c := range cases {
+		combined := CombineOutputs(outputs, c.method)
+		if !closeMatrix(combined, c.want, 1e-7) {
+			t.Errorf("%s: combined %v, want %v", c.method, combined.ToSlices(), c.want)
+		}
+		for j := 0; j < combined.Cols; j++ {
+			sum := 0.0
+			for i := 0; i < combined.Rows; i++ {
+				sum += combined.At(i, j)
+			}
+			if math.Abs(sum-1) > 1e-12 {
+				t.Errorf("%s: column %d sums to %v", c.method, j, sum)
+			}
+		}
+		if argmax := combined.Argmax(); !reflect.DeepEqual(argmax, c.argmax) {
+			t.Errorf("%s: predicts %v, want %v", c.method, argmax, c.argmax)
+		}
+	}
+}
+
+func TestCombineOutputsVoteTie(t *testing.T) {
+	outputs := []*Matrix{
+		MatrixFromSlices([][]float64{{0.9, 0.8}, {0.1, 0.2}}),
+		MatrixFromSlices([][]float64{{0.3, 0.4}, {0.7, 0.6}}),
+	}
+	combined := CombineOutputs(outputs, "vote")
+	want := [][]float64{{0.5, 0.5}, {0.5, 0.5}}
+	if !closeMatrix(combined, want, 0) {
+		t.Fatalf("combined %v, want %v", combined.ToSlices(), want)
+	}
+	if argmax := combined.Argmax(); !reflect.DeepEqual(argmax, []float64{0, 0}) {
+		t.Errorf("a tie predicts %v, want the lowest class", argmax)
+	}
+}
+
+// three single-layer members on two inputs: two barely prefer the larger input, one is
+// confident of the smaller; only the vote follows the majority
+func TestEnsembleCompare(t *testing.T) {
+	member := func(w [][]float64) *Network {
+		return &Network{
+			Sizes:   []int{2, 2},
+			Weights: []*Matrix{MatrixFromSlices(w)},
+			Biases:  []*Matrix{NewMatrix(2, 1)},
+		}
+	}
+	ensemble := &Ensemble{Members: []*Network{
+		member([][]float64{{1, 0}, {0, 1}}),
+		member([][]float64{{1, 0}, {0, 1}}),
+		member([][]float64{{0, 10}, {10, 0}}),
+	}}
+	// the columns are the samples (1, 0) and (0, 1), labelled with their larger input
+	x := MatrixFromSlices([][]float64{{1, 0}, {0, 1}})
+	y := []float64{0, 1}
+
+	// on (1, 0) the weak members give class 0 a probability of 0.731, the confident one 0.0000454:
+	// mean 0.487, a geometric mean well below a half, and averaged weights (2/3, 10/3) all pick class 1,
+	// while two of the three votes go to class 0; (0, 1) is the mirror image
+	executor := concurrent.NewWorkStealingExecutor(2, 10)
+	defer executor.Shutdown()
+	want := map[string]float64{"weights": 0, "mean": 0, "geomean": 0, "vote": 1}
+	if got := ensemble.Compare(executor, x, y); !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare = %v, want %v", got, want)
+	}
+	for method, accuracy := range want {
+		if report := ensemble.Evaluate(executor, method, x, y); report.Accuracy != accuracy {
+			t.Errorf("%s: Evaluate reports an accuracy of %v, want %v", method, report.Accuracy, accuracy)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// the results of running a trained network over a labelled data set
+type Report struct {
+	Dataset   string        `json:"dataset,omitempty"`
+	Samples   int           `json:"samples"`
+	Accuracy  float64       `json:"accuracy"`
+	Loss      float64       `json:"loss"` // mean cross-entropy of the softmax outputs
+	Classes   []ClassReport `json:"classes"`
+	Confusion [][]int       `json:"confusion"` // Confusion[actual][predicted]
+	// the mean training loss of every epoch; for ensembles, averaged over the members weighted by chunk size
+	TrainingLoss []float64 `json:"training_loss,omitempty"`
+	// for ensembles: how the members were combined, and the test accuracy of every combination method
+	Combine      string             `json:"combine,omitempty"`
+	Combinations map[string]float64 `json:"combinations,omitempty"`
+	// the seed the run was trained with; passing it back as Config.Seed reproduces the run
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// precision, recall and F1 for a single class
+type ClassReport struct {
+	Class     int     `json:"class"`
+	Name      string  `json:"name,omitempty"` // what the class stands for, if the data set names its classes
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"` // number of samples whose label is this class
+}
+
+// keeps log(0) out of the loss when the network is certain and wrong
+const minProbability = 1e-12
+
+// runs the network over x and compares its predictions with the labels y
+func Evaluate(net *Network, x *Matrix, y []float64) *Report {
+	zs, as := net.Forward(x)
+	report := EvaluateProbabilities(as[len(as)-1], y)
+	// the loss from the logits is exact even where a probability underflows to 0
+	if len(y) > 0 {
+		report.Loss = SoftmaxCrossEntropyInto(zs[len(zs)-1], y, as[len(as)-1], nil)
+	}
+	return report
+}
+
+// compares predicted class probabilities (classes x m) with the labels y
+// the loss is -log(probability of the label), clamped to minProbability
+// an empty data set has an accuracy and loss of 0 rather than NaN, which JSON can't encode
+func EvaluateProbabilities(probabilities *Matrix, y []float64) *Report {
+	predictions := probabilities.Argmax()
+	classes := probabilities.Rows
+
+	confusion := make([][]int, classes)
+	for i := range confusion {
+		confusion[i] = make([]int, classes)
+	}
+	loss := 0.0
+	for j := range y {
+		actual := int(y[j])
+		confusion[actual][int(predictions[j])]++
+		loss -= math.Log(math.Max(probabilities.At(actual, j), minProbability))
+	}
+
+	report := &Report{
+		Samples:   len(y),
+		Classes:   make([]ClassReport, classes),
+		Confusion: confusion,
+	}
+	if len(y) > 0 {
+		report.Accuracy = GetAccuracy(predictions, y)
+		report.Loss = loss / float64(len(y))
+	}
+	for c := 0; c < classes; c++ {
+		truePositives := confusion[c][c]
+		predicted := 0 // column sum
+		actual := 0    // row sum
+		for k := 0; k < classes; k++ {
+			predicted += confusion[k][c]
+			actual += confusion[c][k]
+		}
+
+		class := ClassReport{Class: c, Support: actual}
+		if predicted > 0 {
+			class.Precision = float64(truePositives) / float64(predicted)
+		}
+		if actual > 0 {
+			class.Recall = float64(truePositives) / float64(actual)
+		}
+		if class.Precision+class.Recall > 0 {
+			class.F1 = 2 * class.Precision * class.Recall / (class.Precision + class.Recall)
+		}
+		report.Classes[c] = class
+	}
+	return report
+}
+
+// gives every class of the report its name from the data set
+func (report *Report) NameClasses(names []string) {
+	for c := range report.Classes {
+		if c < len(names) {
+			report.Classes[c].Name = names[c]
+		}
+	}
+}
+
+// whether any class has a name other than its number (MNIST's digits don't)
+func (report *Report) namedClasses() bool {
+	for _, class := range report.Classes {
+		if class.Name != "" && class.Name != strconv.Itoa(class.Class) {
+			return true
+		}
+	}
+	return false
+}
+
+// prints the report as plain text tables
+func (report *Report) WriteTable(w io.Writer) {
+	if report.Dataset != "" {
+		fmt.Fprintf(w, "dataset   %s\n", report.Dataset)
+	}
+	fmt.Fprintf(w, "samples   %d\n", report.Samples)
+	fmt.Fprintf(w, "accuracy  %.4f\n", report.Accuracy)
+	fmt.Fprintf(w, "loss      %.4f\n", report.Loss)
+	if report.Combine != "" {
+		fmt.Fprintf(w, "combine   %s\n", report.Combine)
+	}
+	if report.Seed != 0 {
+		fmt.Fprintf(w, "seed      %d\n", report.Seed)
+	}
+	fmt.Fprintln(w)
+
+	if len(report.TrainingLoss) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "epoch\ttraining loss\t")
+		for epoch, loss := range report.TrainingLoss {
+			fmt.Fprintf(tw, "%d\t%.4f\t\n", epoch+1, loss)
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	if len(report.Combinations) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "combine\taccuracy\t")
+		for _, method := range CombineNames {
+			if accuracy, ok := report.Combinations[method]; ok {
+				fmt.Fprintf(tw, "%s\t%.4f\t\n", method, accuracy)
+			}
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if report.namedClasses() {
+		fmt.Fprintln(tw, "class\tname\tprecision\trecall\tf1\tsupport\t")
+	} else {
+		fmt.Fprintln(tw, "class\tprecision\trecall\tf1\tsupport\t")
+	}
+	for _, class := range report.Classes {
+		fmt.Fprintf(tw, "%d\t", class.Class)
+		if report.namedClasses() {
+			fmt.Fprintf(tw, "%s\t", class.Name)
+		}
+		fmt.Fprintf(tw, "%.4f\t%.4f\t%.4f\t%d\t\n", class.Precision, class.Recall, class.F1, class.Support)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nconfusion matrix (rows = actual, columns = predicted)")
+	tw = tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "\t")
+	for c := range report.Confusion {
+		fmt.Fprintf(tw, "%d\t", c)
+	}
+	fmt.Fprintln(tw)
+	for actual, row := range report.Confusion {
+		fmt.Fprintf(tw, "%d\t", actual)
+		for _, count := range row {
+			fmt.Fprintf(tw, "%d\t", count)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// writes the report as indented JSON
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writes the report as JSON to the file at path
+func (report *Report) SaveJSON(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteJSON(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateProbabilities(t *testing.T) {
+	// six samples, two of each class; the columns predict 0, 1, 1, 1, 2 and 0
+	probabilities := MatrixFromSlices([][]float64{
+		{0.5, 0.25, 0.25, 0, 0.25, 0.5},
+		{0.25, 0.5, 0.5, 1, 0.25, 0.25},
+		{0.25, 0.25, 0.25, 0, 0.5, 0.25},
+	})
+	y := []float64{0, 0, 1, 1, 2, 2}
+	report := EvaluateProbabilities(probabilities, y)
+
+	confusion := [][]int{
+		{1, 1, 0},
+		{0, 2, 0},
+		{1, 0, 1},
+	}
+	if !reflect.DeepEqual(report.Confusion, confusion) {
+		t.Errorf("confusion matrix %v, want %v", report.Confusion, confusion)
+	}
+	classes := []ClassReport{
+		{Class: 0, Precision: 1.0 / 2, Recall: 1.0 / 2, F1: 1.0 / 2, Support: 2},
+		{Class: 1, Precision: 2.0 / 3, Recall: 1, F1: 4.0 / 5, Support: 2},
+		{Class: 2, Precision: 1, Recall: 1.0 / 2, F1: 2.0 / 3, Support: 2},
+	}
+	for c, want := range classes {
+		got := report.Classes[c]
+		if got.Class != want.Class || got.Support != want.Support ||
+			math.Abs(got.Precision-want.Precision) > 1e-12 ||
+			math.Abs(got.Recall-want.Recall) > 1e-12 ||
+			math.Abs(got.F1-want.F1) > 1e-12 {
+			t.Errorf("class %d: %+v, want %+v", c, got, want)
+		}
+	}
+	if report.Samples != 6 || report.Accuracy != 4.0/6 {
+		t.Errorf("%d samples with an accuracy of %v, want 6 and %v", report.Samples, report.Accuracy, 4.0/6)
+	}
+	// the labels get probabilities 1/2, 1/4, 1/2, 1, 1/2 and 1/4: 7 halvings over 6 samples
+	if want := 7 * math.Ln2 / 6; math.Abs(report.Loss-want) > 1e-12 {
+		t.Errorf("loss %v, want %v", report.Loss, want)
+	}
+}
+
+// an empty test split must still give a report that can be written out
+func TestEvaluateEmpty(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	net := NewNetwork([]int{4, 3, 3}, UniformInit, rng)
+	for name, report := range map[string]*Report{
+		"EvaluateProbabilities": EvaluateProbabilities(NewMatrix(3, 0), nil),
+		"Evaluate":              Evaluate(net, NewMatrix(4, 0), nil),
+	} {
+		if report.Samples != 0 || report.Accuracy != 0 || report.Loss != 0 {
+			t.Errorf("%s: %d samples, accuracy %v, loss %v, want all 0", name, report.Samples, report.Accuracy, report.Loss)
+		}
+		var buf bytes.Buffer
+		if err := report.WriteJSON(&buf); err != nil {
+			t.Errorf("%s: WriteJSON: %v", name, err)
+			continue
+		}
+		var decoded Report
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded.Classes) != 3 {
+			t.Errorf("%s: wrote %s", name, buf.String())
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"fmt"
+	"proj3/concurrent"
+)
+
+// general matrix multiply, as in BLAS: C = alpha * op(A) * op(B) + beta * C
+// where op(X) is X or its transpose
+// the transposes are never materialized: each of the four cases walks A and B in the order they're stored in,
+// in tiles of gemmBlockK x gemmBlockN so the part of B being reused stays in cache
+// large products are split into row blocks of C that run in parallel on the GEMM pool (SetGEMMThreads)
+
+// tile sizes: a gemmBlockK x gemmBlockN tile of B is 256KB
+const (
+	gemmBlockK = 128
+	gemmBlockN = 256
+)
+
+// products with fewer multiply-adds than this aren't worth handing to the pool
+const gemmParallelMin = 1 << 18
+
+// the pool every GEMM shares; nil runs every GEMM on the calling goroutine
+var gemmPool concurrent.ExecutorService
+var gemmThreads = 1
+
+// sets the number of goroutines large GEMMs are split across; 1 (or less) turns intra-op parallelism off
+// it's a process-wide setting, so it must not be changed while any GEMM is running
+// the pool is separate from the scheduler's executors: GEMM tasks never block on other tasks
+// opts are passed on to the pool's executor, e.g. concurrent.WithIdleStrategy
+func SetGEMMThreads(threads int, opts ...concurrent.ExecutorOption) {
+	if gemmPool != nil {
+		gemmPool.Shutdown()
+		gemmPool = nil
+	}
+	gemmThreads = 1
+	if threads > 1 {
+		gemmPool = concurrent.NewWorkStealingExecutor(threads, 10, opts...)
+		gemmThreads = threads
+	}
+}
+
+// C = alpha * op(A) * op(B) + beta * C, where op(X) is X transposed if transX is set
+// C must already have the shape of the product and must not share memory with A or B
+// with beta == 0 the old contents of C are ignored (even NaNs), so C can be uninitialized scratch
+func GEMM(transA, transB bool, alpha float64, a *Matrix, b *Matrix, beta float64, c *Matrix) *Matrix {
+	m, k := a.Rows, a.Cols
+	if transA {
+		m, k = k, m
+	}
+	kb, n := b.Rows, b.Cols
+	if transB {
+		kb, n = n, kb
+	}
+	if k != kb || c.Rows != m || c.Cols != n {
+		panic(fmt.Sprintf("GEMM: can't multiply %s by %s into %dx%d", opShape(a, transA), opShape(b, transB), c.Rows, c.Cols))
+	}
+
+	blocks := 1
+	if gemmPool != nil && m*n*k >= gemmParallelMin {
+		blocks = gemmThreads
+		if blocks > m {
+			blocks = m
+		}
+	}
+	if blocks <= 1 {
+		gemmRows(transA, transB, alpha, a, b, beta, c, 0, m)
+		return c
+	}
+
+	// block i gets rows [i*m/blocks, (i+1)*m/blocks) of C, so every task writes different rows
+	// the calling goroutine runs the first block itself instead of just waiting
+	futures := make([]concurrent.Future, blocks-1)
+	for i := 1; i < blocks; i++ {
+		futures[i-1] = gemmPool.Submit(&gemmTask{transA, transB, alpha, a, b, beta, c, i * m / blocks, (i + 1) * m / blocks})
+	}
+	gemmRows(transA, transB, alpha, a, b, beta, c, 0, m/blocks)
+	for _, future := range futures {
+		future.Get()
+	}
+	return c
+}
+
+func opShape(x *Matrix, trans bool) string {
+	if trans {
+		return fmt.Sprintf("%dx%d (transposed)", x.Cols, x.Rows)
+	}
+	return fmt.Sprintf("%dx%d", x.Rows, x.Cols)
+}
+
+// a gemmTask computes rows [lo, hi) of C
+type gemmTask struct {
+	transA bool
+	transB bool
+	alpha  float64
+	a      *Matrix
+	b      *Matrix
+	beta   float64
+	c      *Matrix
+	lo     int
+	hi     int
+}
+
+func (task *gemmTask) Run() {
+	gemmRows(task.transA, task.transB, task.alpha, task.a, task.b, task.beta, task.c, task.lo, task.hi)
+}
+
+// computes rows [lo, hi) of C = alpha * op(A) * op(B) + beta * C
+func gemmRows(transA, transB bool, alpha float64, a *Matrix, b *Matrix, beta float64, c *Matrix, lo, hi int) {
+	for i := lo; i < hi; i++ {
+		row := c.Row(i)
+		if beta == 0 {
+			for j := range row {
+				row[j] = 0
+			}
+		} else if beta != 1 {
+			for j := range row {
+				row[j] *= beta
+			}
+		}
+	}
+	if alpha == 0 {
+		return
+	}
+
+	k, n := b.Rows, b.Cols
+	if transB {
+		k, n = n, k
+	}
+
+	if !transB {
+		// C[i][j] += alpha * op(A)[i][p] * B[p][j]: the inner loop runs along rows of B and C
+		for pp := 0; pp < k; pp += gemmBlockK {
+			pEnd := min(pp+gemmBlockK, k)
+			for jj := 0; jj < n; jj += gemmBlockN {
+				jEnd := min(jj+gemmBlockN, n)
+				for i := lo; i < hi; i++ {
+					cRow := c.Row(i)[jj:jEnd]
+					for p := pp; p < pEnd; p++ {
+						var aip float64
+						if transA {
+							aip = alpha * a.Data[p*a.Stride+i]
+						} else {
+							aip = alpha * a.Data[i*a.Stride+p]
+						}
+						bRow := b.Row(p)[jj:jEnd]
+						for j, v := range bRow {
+							cRow[j] += aip * v
+						}
+					}
+				}
+			}
+		}
+		return
+	}
+
+	// C[i][j] += alpha * op(A)[i][.] . B[j][.]: a dot product with a row of B for every element of C
+	for jj := 0; jj < n; jj += gemmBlockN {
+		jEnd := min(jj+gemmBlockN, n)
+		for i := lo; i < hi; i++ {
+			cRow := c.Row(i)
+			for j := jj; j < jEnd; j++ {
+				bRow := b.Row(j)
+				sum := 0.0
+				if transA {
+					for p, v := range bRow {
+						sum += a.Data[p*a.Stride+i] * v
+					}
+				} else {
+					aRow := a.Row(i)
+					for p, v := range bRow {
+						sum += aRow[p] * v
+					}
+				}
+				cRow[j] += alpha * sum
+			}
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// a rows x cols view of random values into a wider matrix, so its stride isn't its width
+func randomView(rows, cols int, rng *rand.Rand) *Matrix {
+	m := NewMatrix(rows, cols+3)
+	for i := range m.Data {
+		m.Data[i] = rng.NormFloat64()
+	}
+	return m.ColumnView(2, 2+cols)
+}
+
+// C = alpha * op(A) * op(B) + beta * C by the textbook triple loop
+func naiveGEMM(transA, transB bool, alpha float64, a *Matrix, b *Matrix, beta float64, c *Matrix) *Matrix {
+	at := func(x *Matrix, trans bool, i, j int) float64 {
+		if trans {
+			return x.At(j, i)
+		}
+		return x.At(i, j)
+	}
+	k := a.Cols
+	if transA {
+		k = a.Rows
+	}
+	res := NewMatrix(c.Rows, c.Cols)
+	for i := 0; i < c.Rows; i++ {
+		for j := 0; j < c.Cols; j++ {
+			sum := 0.0
+			for p := 0; p < k; p++ {
+				sum += at(a, transA, i, p) * at(b, transB, p, j)
+			}
+			v := alpha * sum
+			if beta != 0 {
+				v += beta * c.At(i, j)
+			}
+			res.Set(i, j, v)
+		}
+	}
+	return res
+}
+
+// runs GEMM on views and compares it with the triple loop for every combination of transposes
+// the shapes aren't multiples of the gemmBlockK x gemmBlockN tiles, so partial tiles are covered
+func checkGEMM(t *testing.T, m, k, n int) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(int64(m*k*n + 1)))
+	scales := [][2]float64{{1, 0}, {1, 1}, {-0.5, 2.5}, {0, 0.7}, {2, 0}}
+	for _, transA := range []bool{false, true} {
+		for _, transB := range []bool{false, true} {
+			for _, scale := range scales {
+				alpha, beta := scale[0], scale[1]
+				a := randomView(m, k, rng)
+				if transA {
+					a = randomView(k, m, rng)
+				}
+				b := randomView(k, n, rng)
+				if transB {
+					b = randomView(n, k, rng)
+				}
+				c := randomView(m, n, rng)
+				if beta == 0 {
+					for i := 0; i < c.Rows; i++ {
+						for j := range c.Row(i) {
+							c.Row(i)[j] = math.NaN() // beta == 0 must ignore what's in C
+						}
+					}
+				}
+				want := naiveGEMM(transA, transB, alpha, a, b, beta, c)
+				GEMM(transA, transB, alpha, a, b, beta, c)
+
+				diff := 0.0
+				for i := 0; i < m; i++ {
+					for j := 0; j < n; j++ {
+						diff = math.Max(diff, math.Abs(c.At(i, j)-want.At(i, j)))
+					}
+				}
+				if !(diff <= 1e-9*float64(k+1)) {
+					t.Errorf("%dx%dx%d transA=%v transB=%v alpha=%v beta=%v: differs from the triple loop by %g",
+						m, k, n, transA, transB, alpha, beta, diff)
+				}
+			}
+		}
+	}
+}
+
+func TestGEMM(t *testing.T) {
+	SetGEMMThreads(1)
+	shapes := [][3]int{
+		{1, 1, 1},
+		{3, 5, 2},
+		{7, gemmBlockK + 3, 9},                 // crosses a K tile edge
+		{5, 3, gemmBlockN + 45},                // crosses an N tile edge
+		{17, 2*gemmBlockK + 1, gemmBlockN + 1}, // crosses both
+		{4, 0, 6},                              // an empty inner dimension leaves beta * C
+	}
+	for _, shape := range shapes {
+		checkGEMM(t, shape[0], shape[1], shape[2])
+	}
+}
+
+// products above gemmParallelMin are split into row blocks on the GEMM pool; the rows must come out
+// the same as on one goroutine, bit for bit, since every row is computed by the same code either way
+func TestGEMMParallel(t *testing.T) {
+	defer SetGEMMThreads(1)
+	shapes := [][3]int{
+		{70, gemmBlockK + 2, gemmBlockN + 44}, // 4 row blocks
+		{2, 400, 400},                         // fewer rows than threads
+	}
+	for _, shape := range shapes {
+		m, k, n := shape[0], shape[1], shape[2]
+		if m*k*n < gemmParallelMin {
+			t.Fatalf("%v is below gemmParallelMin", shape)
+		}
+		t.Run(fmt.Sprintf("%dx%dx%d", m, k, n), func(t *testing.T) {
+			SetGEMMThreads(4)
+			checkGEMM(t, m, k, n)
+
+			rng := rand.New(rand.NewSource(1))
+			for _, trans := range [][2]bool{{false, false}, {false, true}, {true, false}, {true, true}} {
+				a := randomView(m, k, rng)
+				if trans[0] {
+					a = randomView(k, m, rng)
+				}
+				b := randomView(k, n, rng)
+				if trans[1] {
+					b = randomView(n, k, rng)
+				}
+				c := randomView(m, n, rng)
+				serial := c.Clone()
+				SetGEMMThreads(4)
+				GEMM(trans[0], trans[1], 0.5, a, b, 1.5, c)
+				SetGEMMThreads(1)
+				GEMM(trans[0], trans[1], 0.5, a, b, 1.5, serial)
+				if !sameBitsMatrix(c, serial) {
+					t.Errorf("transA=%v transB=%v: 4 threads and 1 thread give different products", trans[0], trans[1])
+				}
+			}
+		})
+	}
+}
+
+// a forward/backward step of a 784-128-64-10 network on 1000 samples with its multiplies split across
+// 1, 2 and 4 goroutines; go test -bench GEMMThreads -cpu 4 ./scheduler shows the intra-op speedup
+func BenchmarkGEMMThreads(b *testing.B) {
+	defer SetGEMMThreads(1)
+	sizes := []int{784, 128, 64, 10}
+	rng := rand.New(rand.NewSource(1))
+	net := NewNetwork(sizes, UniformInit, rng)
+	x, y := randomBatch(sizes, 1000, rng)
+	for _, threads := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			SetGEMMThreads(threads)
+			for i := 0; i < b.N; i++ {
+				zs, as := net.Forward(x)
+				net.Backward(zs, as, y)
+			}
+		})
+	}
+}
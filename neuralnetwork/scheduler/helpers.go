@@ -4,43 +4,15 @@ import (
 	"proj3/mnist"
 )
 
-// loads in data
-func LoadData(config Config) ([][]float64, []float64, [][]float64, []float64) {
-	// use mnist package to load in training and test data
-	train, test, err := mnist.Load("../../proj3/mnist")
-	if err != nil {
-		panic(err)
+// converts an array of Images to a 784 x len(images) matrix with one image per column
+func ImagesToMatrix(images []*mnist.Image) *Matrix {
+	m := NewMatrix(len(mnist.Image{}), len(images))
+	for j, image := range images {
+		for i := range image {
+			m.Data[i*m.Stride+j] = float64(image[i])
+		}
 	}
-
-	// each image is represented as a 784-byte array
-	// we convert the images and labels to vectors of float64s
-	xTrain := Transpose(ImagesToVectors(train.Images)) // 784x60000
-	yTrain := LabelsToVector(train.Labels)             // 60000x1
-	xTest := Transpose(ImagesToVectors(test.Images))   // 784x10000
-	yTest := LabelsToVector(test.Labels)               // 10000x1
-	ScalarMultiply(1.0/255.0, xTrain)                  // normalize the data
-	Clone(xTrain)
-	ScalarMultiply(1.0/255.0, xTest) // normalize the data
-
-	return xTrain, yTrain, xTest, yTest
-}
-
-// converts an array of Images to an array of vectors
-func ImagesToVectors(images []*mnist.Image) [][]float64 {
-	vectors := make([][]float64, len(images))
-	for i := 0; i < len(images); i++ {
-		vectors[i] = ImageToVector(images[i])
-	}
-	return vectors
-}
-
-// converts an Image to a vector
-func ImageToVector(image *mnist.Image) []float64 {
-	vector := make([]float64, 784)
-	for i := 0; i < 784; i++ {
-		vector[i] = float64(image[i])
-	}
-	return vector
+	return m
 }
 
 // converts Labels to a vector
@@ -52,12 +24,11 @@ func LabelsToVector(labels []mnist.Label) []float64 {
 	return vectors
 }
 
-// Clones a 2D array of floats
-// https://stackoverflow.com/questions/68542702/clone-float-slice-in-go-without-affecting-the-original
-func Clone(arr [][]float64) (res [][]float64) {
-	res = make([][]float64, len(arr))
-	for i := range arr {
-		res[i] = append([]float64{}, arr[i]...)
+// copies the given labels, in the given order
+func SelectLabels(labels []float64, indices []int) []float64 {
+	res := make([]float64, len(indices))
+	for i, index := range indices {
+		res[i] = labels[index]
 	}
-	return
+	return res
 }
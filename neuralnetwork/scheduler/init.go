@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// an Initializer fills in the starting weights (fanOut x fanIn) and biases (fanOut x 1) of one layer
+// it draws every random number from rng, so the same seed always gives the same network
+type Initializer func(weights *Matrix, biases *Matrix, rng *rand.Rand)
+
+// the initializers that can be picked by name from Config / the CLI
+var InitNames = []string{"uniform", "xavier", "he", "lecun"}
+
+// returns the initializer with the given name; "" means uniform
+func NewInitializer(name string) (Initializer, error) {
+	switch name {
+	case "uniform", "":
+		return UniformInit, nil
+	case "xavier":
+		return XavierInit, nil
+	case "he":
+		return HeInit, nil
+	case "lecun":
+		return LeCunInit, nil
+	}
+	return nil, fmt.Errorf("unknown initializer %q (want one of %v)", name, InitNames)
+}
+
+// the original initialization: weights and biases uniform in [-0.5, 0.5), whatever the layer size
+func UniformInit(weights *Matrix, biases *Matrix, rng *rand.Rand) {
+	fillUniform(weights, 0.5, rng)
+	fillUniform(biases, 0.5, rng)
+}
+
+// Xavier / Glorot: weights uniform in [-sqrt(6 / (fanIn + fanOut)), sqrt(6 / (fanIn + fanOut))), biases 0
+// keeps the variance of activations and gradients constant through tanh and sigmoid layers
+func XavierInit(weights *Matrix, biases *Matrix, rng *rand.Rand) {
+	fillUniform(weights, math.Sqrt(6/float64(weights.Cols+weights.Rows)), rng)
+	biases.Zero()
+}
+
+// He / Kaiming: weights normal with variance 2 / fanIn, biases 0
+// the factor 2 makes up for ReLU zeroing half of its inputs
+func HeInit(weights *Matrix, biases *Matrix, rng *rand.Rand) {
+	fillNormal(weights, math.Sqrt(2/float64(weights.Cols)), rng)
+	biases.Zero()
+}
+
+// LeCun: weights normal with variance 1 / fanIn, biases 0
+func LeCunInit(weights *Matrix, biases *Matrix, rng *rand.Rand) {
+	fillNormal(weights, math.Sqrt(1/float64(weights.Cols)), rng)
+	biases.Zero()
+}
+
+// uniform in [-limit, limit)
+func fillUniform(m *Matrix, limit float64, rng *rand.Rand) {
+	for i := 0; i < m.Rows; i++ {
+		row := m.Row(i)
+		for j := range row {
+			row[j] = (2*rng.Float64() - 1) * limit
+		}
+	}
+}
+
+// normal with mean 0 and standard deviation stddev
+func fillNormal(m *Matrix, stddev float64, rng *rand.Rand) {
+	for i := 0; i < m.Rows; i++ {
+		row := m.Row(i)
+		for j := range row {
+			row[j] = rng.NormFloat64() * stddev
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+)
+
+// a dense row-major matrix backed by a single slice
+// element (i, j) is Data[i*Stride+j]; Stride >= Cols, so a Matrix can be a view into a wider one
+// (e.g. a range of columns of the training set) without copying
+//
+// every operation comes in two forms:
+// X(...) allocates and returns a new matrix for the result
+// XInto(..., dst) writes the result into dst, which must already have the result's shape, and returns dst;
+// for element-wise operations dst may be the receiver itself, which makes the operation in place
+type Matrix struct {
+	Rows   int
+	Cols   int
+	Stride int
+	Data   []float64
+}
+
+// a zeroed rows x cols matrix
+func NewMatrix(rows, cols int) *Matrix {
+	return &Matrix{Rows: rows, Cols: cols, Stride: cols, Data: make([]float64, rows*cols)}
+}
+
+// copies a [][]float64 (every row the same length) into a new matrix
+func MatrixFromSlices(a [][]float64) *Matrix {
+	cols := 0
+	if len(a) > 0 {
+		cols = len(a[0])
+	}
+	m := NewMatrix(len(a), cols)
+	for i := range a {
+		copy(m.Row(i), a[i])
+	}
+	return m
+}
+
+// copies the matrix into a new [][]float64
+func (m *Matrix) ToSlices() [][]float64 {
+	a := make([][]float64, m.Rows)
+	for i := range a {
+		a[i] = append([]float64{}, m.Row(i)...)
+	}
+	return a
+}
+
+func (m *Matrix) At(i, j int) float64 {
+	return m.Data[i*m.Stride+j]
+}
+
+func (m *Matrix) Set(i, j int, v float64) {
+	m.Data[i*m.Stride+j] = v
+}
+
+// row i, sharing memory with m
+func (m *Matrix) Row(i int) []float64 {
+	return m.Data[i*m.Stride : i*m.Stride+m.Cols]
+}
+
+// the rows x cols block starting at (i, j), sharing memory with m
+func (m *Matrix) View(i, j, rows, cols int) *Matrix {
+	if i < 0 || j < 0 || rows < 0 || cols < 0 || i+rows > m.Rows || j+cols > m.Cols {
+		panic(fmt.Sprintf("view %dx%d at (%d, %d) is outside a %dx%d matrix", rows, cols, i, j, m.Rows, m.Cols))
+	}
+	if rows == 0 || cols == 0 {
+		return &Matrix{Rows: rows, Cols: cols, Stride: cols}
+	}
+	start := i*m.Stride + j
+	end := (i+rows-1)*m.Stride + j + cols
+	return &Matrix{Rows: rows, Cols: cols, Stride: m.Stride, Data: m.Data[start:end]}
+}
+
+// columns [lo, hi) (samples, for a features x samples matrix), sharing memory with m
+func (m *Matrix) ColumnView(lo, hi int) *Matrix {
+	return m.View(0, lo, m.Rows, hi-lo)
+}
+
+// copies the given columns of m, in the given order, into a new matrix
+func (m *Matrix) SelectColumns(columns []int) *Matrix {
+	return m.SelectColumnsInto(columns, NewMatrix(m.Rows, len(columns)))
+}
+
+func (m *Matrix) SelectColumnsInto(columns []int, dst *Matrix) *Matrix {
+	if dst.Rows != m.Rows || dst.Cols != len(columns) {
+		panic(fmt.Sprintf("SelectColumns: can't select %d columns of a %dx%d matrix into %dx%d", len(columns), m.Rows, m.Cols, dst.Rows, dst.Cols))
+	}
+	for i := 0; i < m.Rows; i++ {
+		row, dstRow := m.Row(i), dst.Row(i)
+		for j, column := range columns {
+			dstRow[j] = row[column]
+		}
+	}
+	return dst
+}
+
+// makes m a contiguous rows x cols matrix over its own backing slice, which only grows if it's too small
+// the contents are left undefined; m must not be a view, since the new shape can cover memory outside it
+func (m *Matrix) reshape(rows, cols int) *Matrix {
+	n := rows * cols
+	if cap(m.Data) < n {
+		m.Data = make([]float64, n)
+	}
+	m.Rows, m.Cols, m.Stride, m.Data = rows, cols, cols, m.Data[:n]
+	return m
+}
+
+func (m *Matrix) SameShape(b *Matrix) bool {
+	return m.Rows == b.Rows && m.Cols == b.Cols
+}
+
+// panics unless a and b have the same shape
+func checkShape(op string, a *Matrix, b *Matrix) {
+	if !a.SameShape(b) {
+		panic(fmt.Sprintf("%s: %dx%d and %dx%d matrices don't have the same shape", op, a.Rows, a.Cols, b.Rows, b.Cols))
+	}
+}
+
+// a contiguous copy of m
+func (m *Matrix) Clone() *Matrix {
+	return m.CopyInto(NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) CopyInto(dst *Matrix) *Matrix {
+	checkShape("CopyInto", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		copy(dst.Row(i), m.Row(i))
+	}
+	return dst
+}
+
+// sets every element to 0
+func (m *Matrix) Zero() *Matrix {
+	for i := 0; i < m.Rows; i++ {
+		row := m.Row(i)
+		for j := range row {
+			row[j] = 0
+		}
+	}
+	return m
+}
+
+// matrix product m * b
+func (m *Matrix) Dot(b *Matrix) *Matrix {
+	return m.DotInto(b, NewMatrix(m.Rows, b.Cols))
+}
+
+// dst must not share memory with m or b
+func (m *Matrix) DotInto(b *Matrix, dst *Matrix) *Matrix {
+	return GEMM(false, false, 1, m, b, 0, dst)
+}
+
+// element-wise m + b
+func (m *Matrix) Add(b *Matrix) *Matrix {
+	return m.AddInto(b, NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) AddInto(b *Matrix, dst *Matrix) *Matrix {
+	checkShape("Add", m, b)
+	checkShape("Add", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, bRow, dstRow := m.Row(i), b.Row(i), dst.Row(i)
+		for j, v := range row {
+			dstRow[j] = v + bRow[j]
+		}
+	}
+	return dst
+}
+
+// element-wise m - b
+func (m *Matrix) Subtract(b *Matrix) *Matrix {
+	return m.SubtractInto(b, NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) SubtractInto(b *Matrix, dst *Matrix) *Matrix {
+	checkShape("Subtract", m, b)
+	checkShape("Subtract", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, bRow, dstRow := m.Row(i), b.Row(i), dst.Row(i)
+		for j, v := range row {
+			dstRow[j] = v - bRow[j]
+		}
+	}
+	return dst
+}
+
+// element-wise m * b
+func (m *Matrix) Multiply(b *Matrix) *Matrix {
+	return m.MultiplyInto(b, NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) MultiplyInto(b *Matrix, dst *Matrix) *Matrix {
+	checkShape("Multiply", m, b)
+	checkShape("Multiply", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, bRow, dstRow := m.Row(i), b.Row(i), dst.Row(i)
+		for j, v := range row {
+			dstRow[j] = v * bRow[j]
+		}
+	}
+	return dst
+}
+
+// m - scalar
+func (m *Matrix) ScalarSubtract(scalar float64) *Matrix {
+	return m.ScalarSubtractInto(scalar, NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) ScalarSubtractInto(scalar float64, dst *Matrix) *Matrix {
+	checkShape("ScalarSubtract", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, dstRow := m.Row(i), dst.Row(i)
+		for j, v := range row {
+			dstRow[j] = v - scalar
+		}
+	}
+	return dst
+}
+
+// scalar * m
+func (m *Matrix) ScalarMultiply(scalar float64) *Matrix {
+	return m.ScalarMultiplyInto(scalar, NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) ScalarMultiplyInto(scalar float64, dst *Matrix) *Matrix {
+	checkShape("ScalarMultiply", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, dstRow := m.Row(i), dst.Row(i)
+		for j, v := range row {
+			dstRow[j] = v * scalar
+		}
+	}
+	return dst
+}
+
+// adds the column vector v (m.Rows x 1) to every column of m
+func (m *Matrix) AddVector(v *Matrix) *Matrix {
+	return m.AddVectorInto(v, NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) AddVectorInto(v *Matrix, dst *Matrix) *Matrix {
+	if v.Rows != m.Rows || v.Cols != 1 {
+		panic(fmt.Sprintf("AddVector: can't add a %dx%d vector to a %dx%d matrix", v.Rows, v.Cols, m.Rows, m.Cols))
+	}
+	checkShape("AddVector", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, dstRow := m.Row(i), dst.Row(i)
+		b := v.Data[i*v.Stride]
+		for j, x := range row {
+			dstRow[j] = x + b
+		}
+	}
+	return dst
+}
+
+// the transpose of m
+func (m *Matrix) Transpose() *Matrix {
+	return m.TransposeInto(NewMatrix(m.Cols, m.Rows))
+}
+
+// dst must not share memory with m
+func (m *Matrix) TransposeInto(dst *Matrix) *Matrix {
+	if dst.Rows != m.Cols || dst.Cols != m.Rows {
+		panic(fmt.Sprintf("Transpose: can't transpose %dx%d into %dx%d", m.Rows, m.Cols, dst.Rows, dst.Cols))
+	}
+	for i := 0; i < m.Rows; i++ {
+		for j, v := range m.Row(i) {
+			dst.Data[j*dst.Stride+i] = v
+		}
+	}
+	return dst
+}
+
+// element-wise max(m, 0)
+func (m *Matrix) ReLU() *Matrix {
+	return m.ReLUInto(NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) ReLUInto(dst *Matrix) *Matrix {
+	checkShape("ReLU", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, dstRow := m.Row(i), dst.Row(i)
+		for j, v := range row {
+			if v < 0 {
+				v = 0
+			}
+			dstRow[j] = v
+		}
+	}
+	return dst
+}
+
+// element-wise derivative of ReLU: 1 where m > 0, otherwise 0
+func (m *Matrix) DerivativeReLU() *Matrix {
+	return m.DerivativeReLUInto(NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) DerivativeReLUInto(dst *Matrix) *Matrix {
+	checkShape("DerivativeReLU", m, dst)
+	for i := 0; i < m.Rows; i++ {
+		row, dstRow := m.Row(i), dst.Row(i)
+		for j, v := range row {
+			if v <= 0 {
+				dstRow[j] = 0
+			} else {
+				dstRow[j] = 1
+			}
+		}
+	}
+	return dst
+}
+
+// softmax of every column of m
+// the column's largest value is subtracted before exponentiating, so exp never overflows
+// and the largest entry always contributes exp(0) = 1 to the sum: any finite logits give finite probabilities
+func (m *Matrix) Softmax() *Matrix {
+	return m.SoftmaxInto(NewMatrix(m.Rows, m.Cols))
+}
+
+func (m *Matrix) SoftmaxInto(dst *Matrix) *Matrix {
+	checkShape("Softmax", m, dst)
+	for j := 0; j < m.Cols; j++ {
+		softmaxColumn(m, j, dst)
+	}
+	return dst
+}
+
+// writes the softmax of column j of logits into column j of dst (which may be logits)
+// and returns the column's log-sum-exp, log(sum_i exp(z_i)) = max + log(sum_i exp(z_i - max))
+func softmaxColumn(logits *Matrix, j int, dst *Matrix) float64 {
+	max := math.Inf(-1)
+	for i := 0; i < logits.Rows; i++ {
+		max = math.Max(max, logits.Data[i*logits.Stride+j])
+	}
+	sum := 0.0
+	for i := 0; i < logits.Rows; i++ {
+		e := math.Exp(logits.Data[i*logits.Stride+j] - max)
+		dst.Data[i*dst.Stride+j] = e
+		sum += e
+	}
+	for i := 0; i < logits.Rows; i++ {
+		dst.Data[i*dst.Stride+j] /= sum
+	}
+	return max + math.Log(sum)
+}
+
+// the fused softmax and cross-entropy loss of a classes x m matrix of logits against the labels
+// probabilities gets the softmax of every column (it may be logits itself)
+// gradient, unless nil, gets the derivative of the summed loss with respect to the logits: probabilities - onehot(labels)
+// returns the mean loss, computed from the logits as log-sum-exp(z) - z[label] instead of -log(probability),
+// so it stays exact (and finite) even when the probability of the label underflows to 0
+func SoftmaxCrossEntropyInto(logits *Matrix, labels []float64, probabilities *Matrix, gradient *Matrix) float64 {
+	checkShape("SoftmaxCrossEntropy", logits, probabilities)
+	if logits.Cols != len(labels) {
+		panic(fmt.Sprintf("SoftmaxCrossEntropy: %d labels but %d columns", len(labels), logits.Cols))
+	}
+	if gradient != nil {
+		checkShape("SoftmaxCrossEntropy", logits, gradient)
+	}
+	loss := 0.0
+	for j, label := range labels {
+		class := int(label)
+		target := logits.Data[class*logits.Stride+j] // read before probabilities can overwrite it
+		loss += softmaxColumn(logits, j, probabilities) - target
+		if gradient != nil {
+			for i := 0; i < logits.Rows; i++ {
+				gradient.Data[i*gradient.Stride+j] = probabilities.Data[i*probabilities.Stride+j]
+			}
+			gradient.Data[class*gradient.Stride+j] -= 1
+		}
+	}
+	return loss / float64(len(labels))
+}
+
+// the sum of every row of m, as a m.Rows x 1 column vector
+func (m *Matrix) SumRows() *Matrix {
+	return m.SumRowsInto(NewMatrix(m.Rows, 1))
+}
+
+func (m *Matrix) SumRowsInto(dst *Matrix) *Matrix {
+	if dst.Rows != m.Rows || dst.Cols != 1 {
+		panic(fmt.Sprintf("SumRows: can't sum the rows of a %dx%d matrix into %dx%d", m.Rows, m.Cols, dst.Rows, dst.Cols))
+	}
+	for i := 0; i < m.Rows; i++ {
+		sum := 0.0
+		for _, v := range m.Row(i) {
+			sum += v
+		}
+		dst.Data[i*dst.Stride] = sum
+	}
+	return dst
+}
+
+// the sum of every element of m
+func (m *Matrix) Sum() float64 {
+	sum := 0.0
+	for i := 0; i < m.Rows; i++ {
+		for _, v := range m.Row(i) {
+			sum += v
+		}
+	}
+	return sum
+}
+
+// the row index of the largest element of every column (the predicted class of every sample)
+func (m *Matrix) Argmax() []float64 {
+	return m.ArgmaxInto(make([]float64, m.Cols))
+}
+
+func (m *Matrix) ArgmaxInto(dst []float64) []float64 {
+	if len(dst) != m.Cols {
+		panic(fmt.Sprintf("Argmax: %d columns but room for %d results", m.Cols, len(dst)))
+	}
+	for j := 0; j < m.Cols; j++ {
+		best := 0
+		for i := 1; i < m.Rows; i++ {
+			if m.Data[i*m.Stride+j] > m.Data[best*m.Stride+j] {
+				best = i
+			}
+		}
+		dst[j] = float64(best)
+	}
+	return dst
+}
+
+// one hot encoding of the labels as a classes x len(labels) matrix
+func OneHotMatrix(labels []float64, classes int) *Matrix {
+	return OneHotInto(labels, NewMatrix(classes, len(labels)))
+}
+
+func OneHotInto(labels []float64, dst *Matrix) *Matrix {
+	if dst.Cols != len(labels) {
+		panic(fmt.Sprintf("OneHot: %d labels but %d columns", len(labels), dst.Cols))
+	}
+	dst.Zero()
+	for j, label := range labels {
+		dst.Data[int(label)*dst.Stride+j] = 1
+	}
+	return dst
+}
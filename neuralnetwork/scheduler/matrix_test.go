@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// logits of +-1000 overflow exp(z) and underflow every probability but one,
+// which used to give NaN probabilities (patched to 0) and an infinite loss
+func TestSoftmaxCrossEntropyExtremeLogits(t *testing.T) {
+	logits := MatrixFromSlices([][]float64{
+		{1000, -1000, 1000, 0},
+		{-1000, 1000, 1000, 0},
+		{0, 0, -1000, 0},
+	})
+	labels := []float64{0, 0, 2, 1}
+	probabilities := NewMatrix(logits.Rows, logits.Cols)
+	gradient := NewMatrix(logits.Rows, logits.Cols)
+	loss := SoftmaxCrossEntropyInto(logits, labels, probabilities, gradient)
+
+	// columns: certain and right (0), certain and wrong by 2000 (2000), wrong by 2000 with a tie (2000 + log 2), uniform (log 3)
+	want := (0 + 2000 + 2000 + math.Log(2) + math.Log(3)) / 4
+	if math.IsNaN(loss) || math.IsInf(loss, 0) || math.Abs(loss-want) > 1e-9 {
+		t.Errorf("cross-entropy of extreme logits is %v, want %v", loss, want)
+	}
+
+	softmax := logits.Softmax()
+	wantProbabilities := [][]float64{
+		{1, 0, 0.5, 1.0 / 3},
+		{0, 1, 0.5, 1.0 / 3},
+		{0, 0, 0, 1.0 / 3},
+	}
+	for i := range wantProbabilities {
+		for j, p := range wantProbabilities[i] {
+			wantGradient := p
+			if int(labels[j]) == i {
+				wantGradient--
+			}
+			if got := softmax.At(i, j); math.Abs(got-p) > 1e-12 {
+				t.Errorf("softmax (%d, %d) = %v, want %v", i, j, got, p)
+			}
+			if got := probabilities.At(i, j); math.Abs(got-p) > 1e-12 {
+				t.Errorf("probability (%d, %d) = %v, want %v", i, j, got, p)
+			}
+			if got := gradient.At(i, j); math.Abs(got-wantGradient) > 1e-12 {
+				t.Errorf("gradient (%d, %d) = %v, want %v", i, j, got, wantGradient)
+			}
+		}
+	}
+}
+
+// the probabilities may overwrite the logits they are computed from
+func TestSoftmaxCrossEntropyInPlace(t *testing.T) {
+	logits := MatrixFromSlices([][]float64{{1, 2}, {3, -1}})
+	labels := []float64{1, 0}
+	want := SoftmaxCrossEntropyInto(logits, labels, NewMatrix(2, 2), nil)
+	probabilities := logits.Softmax()
+	if got := SoftmaxCrossEntropyInto(logits, labels, logits, nil); got != want {
+		t.Errorf("in place loss %v, want %v", got, want)
+	}
+	for i := range logits.Data {
+		if logits.Data[i] != probabilities.Data[i] {
+			t.Fatalf("in place probabilities %v, want %v", logits.ToSlices(), probabilities.ToSlices())
+		}
+	}
+}
+
+// the Matrix operations against the [][]float64 code they replaced (slices_test.go), on the default
+// 784-10-10 network and a full batch of 1000 samples
+// go test -bench . -benchmem ./scheduler reports the time, bytes and allocations of both
+type benchmarkFixture struct {
+	net              *Network
+	x                *Matrix
+	y                []float64
+	weights, biases  [][][]float64
+	xSlices, zSlices [][]float64
+	z                *Matrix
+}
+
+func newBenchmarkFixture() *benchmarkFixture {
+	sizes := []int{784, 10, 10}
+	rng := rand.New(rand.NewSource(1))
+	f := &benchmarkFixture{net: NewNetwork(sizes, UniformInit, rng)}
+	f.x, f.y = randomBatch(sizes, 1000, rng)
+	f.weights, f.biases = toSlices(f.net.Weights), toSlices(f.net.Biases)
+	f.xSlices = f.x.ToSlices()
+	f.z = f.net.Weights[0].Dot(f.x)
+	f.zSlices = f.z.ToSlices()
+	return f
+}
+
+// runs the [][]float64 and the Matrix version of an operation as the sub-benchmarks slices and matrix
+func benchmarkPair(b *testing.B, slices func(), matrix func()) {
+	for _, bench := range []struct {
+		name string
+		op   func()
+	}{{"slices", slices}, {"matrix", matrix}} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bench.op()
+			}
+		})
+	}
+}
+
+func BenchmarkDot(b *testing.B) {
+	f := newBenchmarkFixture()
+	w, wSlices := f.net.Weights[0], f.weights[0]
+	benchmarkPair(b, func() { legacyDot(wSlices, f.xSlices) }, func() { w.Dot(f.x) })
+}
+
+func BenchmarkDotInto(b *testing.B) {
+	f := newBenchmarkFixture()
+	w, wSlices := f.net.Weights[0], f.weights[0]
+	dst := NewMatrix(f.z.Rows, f.z.Cols)
+	benchmarkPair(b, func() { legacyDot(wSlices, f.xSlices) }, func() { w.DotInto(f.x, dst) })
+}
+
+// the weight gradient of the first layer: z * x^T, which GEMM computes without transposing x
+func BenchmarkDotTransposed(b *testing.B) {
+	f := newBenchmarkFixture()
+	gradient := NewMatrix(f.z.Rows, f.x.Rows)
+	benchmarkPair(b,
+		func() { legacyDot(f.zSlices, legacyTranspose(f.xSlices)) },
+		func() { GEMM(false, true, 1, f.z, f.x, 0, gradient) })
+}
+
+func BenchmarkTranspose(b *testing.B) {
+	f := newBenchmarkFixture()
+	dst := NewMatrix(f.x.Cols, f.x.Rows)
+	benchmarkPair(b, func() { legacyTranspose(f.xSlices) }, func() { f.x.TransposeInto(dst) })
+}
+
+func BenchmarkSoftmax(b *testing.B) {
+	f := newBenchmarkFixture()
+	dst := NewMatrix(f.z.Rows, f.z.Cols)
+	benchmarkPair(b, func() { legacySoftmax(f.zSlices) }, func() { f.z.SoftmaxInto(dst) })
+}
+
+// ReLU on a copy, as the forward pass needs z again for back propagation
+func BenchmarkReLU(b *testing.B) {
+	f := newBenchmarkFixture()
+	benchmarkPair(b, func() { legacyReLU(legacyClone(f.zSlices)) }, func() { f.z.ReLU() })
+}
+
+func BenchmarkForwardBackward(b *testing.B) {
+	f := newBenchmarkFixture()
+	benchmarkPair(b,
+		func() { legacyGradients(f.weights, f.biases, f.xSlices, f.y) },
+		func() {
+			zs, as := f.net.Forward(f.x)
+			f.net.Backward(zs, as, f.y)
+		})
+}
@@ -1,376 +1,155 @@
 package scheduler
 
-import (
-	"math"
-	"math/rand"
-	"time"
-)
+import "math/rand"
 
 // referenced https://www.youtube.com/watch?v=w8yWXqWQYmU for functions directly related to the neural network
 // referenced ChatGPT for some functions related to matrix operations
 
-type weightsAndBiases struct {
-	w1 [][]float64
-	b1 [][]float64
-	w2 [][]float64
-	b2 [][]float64
+// a fully connected network built from a list of layer sizes, e.g. []int{784, 128, 64, 10}
+// Sizes[0] is the number of inputs and the last size is the number of outputs
+// layer l has a Sizes[l+1]xSizes[l] weight matrix and a Sizes[l+1]x1 bias vector
+// hidden layer l applies Activations[l] (ReLU unless set otherwise) and the output layer always uses softmax
+type Network struct {
+	Sizes       []int
+	Weights     []*Matrix
+	Biases      []*Matrix
+	Activations []Activation // one per hidden layer
 }
 
-// initialize weights and biases
-// weight1 is 10x784, bias1 is 10x1, weight2 is 10x10, bias2 is 10x1
-// weights are initialized to random values between -0.5 and 0.5
-// biases are initialized to 0
-func Init_params() ([][]float64, [][]float64, [][]float64, [][]float64) {
-	rand.Seed(time.Now().UnixNano()) // https://stackoverflow.com/questions/68203678/golang-rand-int-why-every-time-same-values
-	w1 := make([][]float64, 10)
-	b1 := make([][]float64, 10)
-	w2 := make([][]float64, 10)
-	b2 := make([][]float64, 10)
-	for i := 0; i < 10; i++ {
-		w1[i] = make([]float64, 784)
-		b1[i] = make([]float64, 1)
-		w2[i] = make([]float64, 10)
-		b2[i] = make([]float64, 1)
+// the hidden layer sizes of the original 784 -> 10 -> 10 network
+// the input and output sizes come from the data set
+var DefaultHiddenLayers = []int{10}
 
-		// biases set to 0
-		b1[i][0] = rand.Float64() - 0.5
-		b2[i][0] = rand.Float64() - 0.5
-
-		// random weights for w1
-		for j := 0; j < 784; j++ {
-			w1[i][j] = rand.Float64() - 0.5
-		}
-		// random weights for w2
-		for j := 0; j < 10; j++ {
-			w2[i][j] = rand.Float64() - 0.5
-		}
+// initialize weights and biases with init, drawing every random number from rng
+// every hidden layer uses ReLU; set Activations to change that
+func NewNetwork(sizes []int, init Initializer, rng *rand.Rand) *Network {
+	layers := len(sizes) - 1
+	net := &Network{
+		Sizes:       append([]int{}, sizes...),
+		Weights:     make([]*Matrix, layers),
+		Biases:      make([]*Matrix, layers),
+		Activations: make([]Activation, layers-1),
 	}
-	return w1, b1, w2, b2
-}
-
-// computes the dot product of two matrices
-func Dot(a [][]float64, b [][]float64) [][]float64 {
-	aRows := len(a)
-	bRows := len(b)
-	bCols := len(b[0])
-
-	// create a new array to store the dot product
-	// has the same number of rows as A and the same number of columns as B
-	c := make([][]float64, aRows)
-	for i := range c {
-		c[i] = make([]float64, bCols)
+	for l := 0; l < layers; l++ {
+		net.Weights[l] = NewMatrix(sizes[l+1], sizes[l])
+		net.Biases[l] = NewMatrix(sizes[l+1], 1)
+		init(net.Weights[l], net.Biases[l], rng)
 	}
-
-	// compute the dot product of each row of A and each column of B
-	for i := 0; i < aRows; i++ {
-		for j := 0; j < bCols; j++ {
-			sum := 0.0
-			for k := 0; k < bRows; k++ {
-				sum += a[i][k] * b[k][j]
-			}
-			c[i][j] = sum
-		}
+	for l := range net.Activations {
+		net.Activations[l] = relu{}
 	}
-	return c
+	return net
 }
 
-// adds matrices of equal size
-func Add(a [][]float64, b [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			a[i][j] += b[i][j]
-		}
-	}
-	return a
+// number of weight layers (one less than the number of sizes)
+func (net *Network) Layers() int {
+	return len(net.Weights)
 }
 
-// subtracts matrices of equal size
-func Subtract(a [][]float64, b [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			a[i][j] -= b[i][j]
-		}
+// deep copy of the network
+func (net *Network) Clone() *Network {
+	clone := &Network{
+		Sizes:       append([]int{}, net.Sizes...),
+		Weights:     make([]*Matrix, net.Layers()),
+		Biases:      make([]*Matrix, net.Layers()),
+		Activations: append([]Activation{}, net.Activations...), // activations have no state to copy
 	}
-	return a
-}
-
-// element-wise multiplication of matrices of equal size
-func Multiply(a [][]float64, b [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			a[i][j] *= b[i][j]
-		}
-	}
-	return a
-}
-
-// subtracts scalar from matrix
-func ScalarSubtract(a [][]float64, scalar float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			a[i][j] -= scalar
-		}
+	for l := range net.Weights {
+		clone.Weights[l] = net.Weights[l].Clone()
+		clone.Biases[l] = net.Biases[l].Clone()
 	}
-	return a
+	return clone
 }
 
-// multiples matrix by a scalar
-func ScalarMultiply(scalar float64, a [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			a[i][j] *= scalar
-		}
-	}
-	return a
-}
-
-// matrix multiply
-func MatrixMultiply(a, b [][]float64) [][]float64 {
-	aRows, aCols := len(a), len(a[0])
-	_, bCols := len(b), len(b[0])
-
-	// make a new array to store the product
-	c := make([][]float64, aRows)
-	for i := range c {
-		c[i] = make([]float64, bCols)
-	}
-
-	for i := 0; i < aRows; i++ {
-		for j := 0; j < bCols; j++ {
-			var sum float64
-			for k := 0; k < aCols; k++ {
-				sum += a[i][k] * b[k][j]
-			}
-			c[i][j] = sum
-		}
-	}
-	return c
-}
-
-// adds a vector to a matrix, row-wise
-func AddVectorToMatrix(a [][]float64, b [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			a[i][j] += b[i][0]
-		}
-	}
-	return a
+// forward propagation
+// returns the pre-activations zs[l] and the activations as[l+1] of every layer; as[0] is x itself
+// every matrix is (layer size) x m
+func (net *Network) Forward(x *Matrix) ([]*Matrix, []*Matrix) {
+	zs, as := emptyMatrices(net.Layers()), emptyMatrices(net.Layers()+1)
+	net.forwardInto(x, zs, as)
+	return zs, as
 }
 
-// transpose a matrix
-func Transpose(a [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-	aT := make([][]float64, cols)
-
-	for i := 0; i < cols; i++ {
-		aT[i] = make([]float64, rows)
-		for j := 0; j < rows; j++ {
-			aT[i][j] = a[j][i]
-		}
-	}
-	return aT
+// forward propagation into existing matrices: zs[l] and as[l+1] are reshaped to fit, and as[0] is set to x
+func (net *Network) forwardInto(x *Matrix, zs []*Matrix, as []*Matrix) {
+	net.logitsInto(x, zs, as)
+	logits := zs[len(zs)-1]
+	softmax{}.Forward(logits, as[len(as)-1].reshape(logits.Rows, logits.Cols))
 }
 
-// ReLU activation function
-// returns 0 if Z < 0, otherwise returns Z
-func ReLU(a [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			if a[i][j] < 0 {
-				a[i][j] = 0
-			}
+// forward propagation up to the logits (the output layer's zs), without the output softmax
+// training computes the softmax together with the loss (lossInto) instead
+func (net *Network) logitsInto(x *Matrix, zs []*Matrix, as []*Matrix) {
+	layers := net.Layers()
+	as[0] = x
+	for l := 0; l < layers; l++ {
+		z := zs[l].reshape(net.Sizes[l+1], x.Cols)
+		GEMM(false, false, 1, net.Weights[l], as[l], 0, z)
+		z.AddVectorInto(net.Biases[l], z)
+		if l < layers-1 {
+			net.Activations[l].Forward(z, as[l+1].reshape(z.Rows, z.Cols))
 		}
 	}
-
-	return a
 }
 
-// derivative of ReLU
-// returns 0 if Z < 0, otherwise returns 1
-func DerivativeReLU(a [][]float64) [][]float64 {
-	rows := len(a)
-	cols := len(a[0])
-
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			if a[i][j] <= 0 {
-				a[i][j] = 0
-			} else {
-				a[i][j] = 1
-			}
-		}
-	}
-
-	return a
+// the mean cross-entropy loss of the output softmax on the labels y
+// also writes the softmax to the output activations and its gradient (probabilities - onehot) to the last of dzs
+func (net *Network) lossInto(zs []*Matrix, as []*Matrix, y []float64, dzs []*Matrix) float64 {
+	logits := zs[len(zs)-1]
+	probabilities := as[len(as)-1].reshape(logits.Rows, logits.Cols)
+	return SoftmaxCrossEntropyInto(logits, y, probabilities, dzs[len(dzs)-1].reshape(logits.Rows, logits.Cols))
 }
 
-// softmax activation function
-func Softmax(matrix [][]float64) [][]float64 {
-	rows := len(matrix)
-	cols := len(matrix[0])
-	softmaxed := make([][]float64, rows)
-
-	for i := 0; i < rows; i++ {
-		softmaxed[i] = make([]float64, cols)
-	}
-
-	for j := 0; j < cols; j++ {
-		colSum := 0.0
-		for i := 0; i < rows; i++ {
-			colSum += math.Exp(matrix[i][j])
-		}
-		for i := 0; i < rows; i++ {
-			softmaxed[i][j] = math.Exp(matrix[i][j]) / colSum
-			if math.IsNaN(softmaxed[i][j]) {
-				softmaxed[i][j] = 0
-			}
-		}
+// back propagation of the mean cross-entropy loss
+// walks the layers from the output back to the input and returns the gradients of every weight and bias
+func (net *Network) Backward(zs []*Matrix, as []*Matrix, y []float64) ([]*Matrix, []*Matrix) {
+	layers := net.Layers()
+	dw := make([]*Matrix, layers)
+	db := make([]*Matrix, layers)
+	for l := 0; l < layers; l++ {
+		dw[l] = NewMatrix(net.Weights[l].Rows, net.Weights[l].Cols)
+		db[l] = NewMatrix(net.Biases[l].Rows, 1)
 	}
-
-	return softmaxed
+	dzs := emptyMatrices(layers)
+	net.lossInto(zs, as, y, dzs)
+	net.backwardInto(zs, as, dzs, dw, db)
+	return dw, db
 }
 
-// one hot encoding for labels
-func OneHot(labels []float64) [][]float64 {
-	oneHotY := make([][]float64, len(labels))
-	for i := range oneHotY {
-		oneHotY[i] = make([]float64, 10)
-		oneHotY[i][int(labels[i])] = 1
-	}
-	return Transpose(oneHotY)
-}
+// back propagation into existing matrices, starting from the output gradient lossInto left in the last of dzs
+// dzs[l] (the gradient with respect to zs[l]) is reshaped to fit; dw and db must already have their final shapes
+func (net *Network) backwardInto(zs []*Matrix, as []*Matrix, dzs []*Matrix, dw []*Matrix, db []*Matrix) {
+	layers := net.Layers()
+	m := float64(dzs[layers-1].Cols)
 
-// argmax function
-func Argmax(a [][]float64) []float64 {
-	max := make([]float64, len(a[0]))
-	for i := 0; i < len(a[0]); i++ {
-		max[i] = 0
-		for j := 0; j < len(a); j++ {
-			if a[j][i] > a[int(max[i])][i] {
-				max[i] = float64(j)
-			}
+	for l := layers - 1; l >= 0; l-- {
+		dz := dzs[l]
+		GEMM(false, true, 1/m, dz, as[l], 0, dw[l]) // dz * as[l]^T / m
+		dz.SumRowsInto(db[l])
+		db[l].ScalarMultiplyInto(1/m, db[l])
+		if l > 0 {
+			prev := dzs[l-1].reshape(net.Sizes[l], dz.Cols)
+			GEMM(true, false, 1, net.Weights[l], dz, 0, prev) // W^T * dz, the gradient with respect to as[l]
+			net.Activations[l-1].Backward(zs[l-1], as[l], prev)
 		}
 	}
-	return max
 }
 
-// Sum 2D matrix
-func Sum(a [][]float64) float64 {
-	sum := 0.0
-	for i := 0; i < len(a); i++ {
-		for j := 0; j < len(a[i]); j++ {
-			sum += a[i][j]
-		}
-	}
-	return sum
+// the mean cross-entropy loss of the network on (x, y)
+func (net *Network) Loss(x *Matrix, y []float64) float64 {
+	zs, as := emptyMatrices(net.Layers()), emptyMatrices(net.Layers()+1)
+	net.logitsInto(x, zs, as)
+	logits := zs[len(zs)-1]
+	return SoftmaxCrossEntropyInto(logits, y, NewMatrix(logits.Rows, logits.Cols), nil)
 }
 
-// Sum 2D matrix row-wise; returns a 2D matrix with 1 column
-func SumRows(matrix [][]float64) [][]float64 {
-	sums := make([][]float64, 0)
-
-	for i := 0; i < len(matrix); i++ {
-		sum := 0.0
-		for j := 0; j < len(matrix[i]); j++ {
-			sum += matrix[i][j]
-		}
-		sums = append(sums, []float64{sum})
+// updates the parameters in place
+// the gradients are scaled in place too, so they can't be reused afterwards
+func (net *Network) Update(dw []*Matrix, db []*Matrix, learningRate float64) {
+	for l := range net.Weights {
+		net.Weights[l].SubtractInto(dw[l].ScalarMultiplyInto(learningRate, dw[l]), net.Weights[l])
+		net.Biases[l].SubtractInto(db[l].ScalarMultiplyInto(learningRate, db[l]), net.Biases[l])
 	}
-
-	return sums
-}
-
-// forward propagation
-func Forward_prop(w1 [][]float64, b1 [][]float64, w2 [][]float64, b2 [][]float64, x [][]float64) ([][]float64, [][]float64, [][]float64, [][]float64) {
-	w1copy := Clone(w1)
-	xcopy := Clone(x)
-	b1copy := Clone(b1)
-	z1 := AddVectorToMatrix(Dot(w1copy, xcopy), b1copy) // 10 x m
-
-	z1copy := Clone(z1)
-	a1 := ReLU(z1copy) // 10 x m
-
-	w2copy := Clone(w2)
-	a1copy := Clone(a1)
-	b2copy := Clone(b2)
-	z2 := AddVectorToMatrix(Dot(w2copy, a1copy), b2copy) // 10 x m
-
-	z2copy := Clone(z2)
-	a2 := Softmax(z2copy) // 10 x m
-	return z1, a1, z2, a2
-}
-
-// back propagation
-func Back_prop(z1 [][]float64, a1 [][]float64, z2 [][]float64, a2 [][]float64, w1 [][]float64, w2 [][]float64, x [][]float64, y []float64) ([][]float64, [][]float64, [][]float64, [][]float64) {
-	ycopy := make([]float64, len(y))
-	copy(ycopy, y)
-	oneHotY := OneHot(ycopy)
-
-	a2copy := Clone(a2)
-	oneHotYcopy := Clone(oneHotY)
-	dz2 := ScalarMultiply(2, Subtract(a2copy, oneHotYcopy))
-
-	dz2copy := Clone(dz2)
-	a1copy := Clone(a1)
-	dw2 := ScalarMultiply(1/float64(len(y)), Dot(dz2copy, Transpose(a1copy)))
-
-	dz2copy2 := Clone(dz2)
-	db2 := ScalarMultiply(1.0/float64(len(y)), SumRows(dz2copy2))
-
-	w2copy := Clone(w2)
-	dz2copy3 := Clone(dz2)
-	z1copy := Clone(z1)
-	dz1 := Multiply(Dot(Transpose(w2copy), dz2copy3), DerivativeReLU(z1copy))
-
-	dz1copy := Clone(dz1)
-	xcopy := Clone(x)
-	dw1 := ScalarMultiply(1/float64(len(y)), Dot(dz1copy, Transpose(xcopy)))
-
-	dz1copy2 := Clone(dz1)
-	db1 := ScalarMultiply(1/float64(len(y)), SumRows(dz1copy2))
-	return dw1, db1, dw2, db2
-}
-
-// updates the parameters
-func UpdateParameters(w1 [][]float64, b1 [][]float64, w2 [][]float64, b2 [][]float64, dw1 [][]float64, db1 [][]float64, dw2 [][]float64, db2 [][]float64, learningRate float64) ([][]float64, [][]float64, [][]float64, [][]float64) {
-	w1copy := Clone(w1)
-	dw1copy := Clone(dw1)
-	w1 = Subtract(w1copy, ScalarMultiply(learningRate, dw1copy))
-
-	b1copy := Clone(b1)
-	db1copy := Clone(db1)
-	b1 = Subtract(b1copy, ScalarMultiply(learningRate, db1copy))
-
-	w2copy := Clone(w2)
-	dw2copy := Clone(dw2)
-	w2 = Subtract(w2copy, ScalarMultiply(learningRate, dw2copy))
-
-	b2copy := Clone(b2)
-	db2copy := Clone(db2)
-	b2 = Subtract(b2copy, ScalarMultiply(learningRate, db2copy))
-	return w1, b1, w2, b2
 }
 
 // get accuracy of the model
@@ -384,102 +163,65 @@ func GetAccuracy(yPred []float64, y []float64) float64 {
 	return accuracy / float64(len(y))
 }
 
-// forward prop => back prop => update params => repeat
-func GradientDescent(x [][]float64, y []float64, learningRate float64, epochs int) weightsAndBiases {
-	w1, b1, w2, b2 := Init_params() // these are coming out fine every epoch
-	for i := 0; i < epochs; i++ {
-		w1copy := Clone(w1)
-		b1copy := Clone(b1)
-		w2copy := Clone(w2)
-		b2copy := Clone(b2)
-
-		w1copy2 := Clone(w1)
-		w2copy2 := Clone(w2)
-
-		w1copy3 := Clone(w1)
-		b1copy3 := Clone(b1)
-		w2copy3 := Clone(w2)
-		b2copy3 := Clone(b2)
-
-		xcopy := Clone(x)
-		xcopy2 := Clone(x)
-		z1, a1, z2, a2 := Forward_prop(w1copy, b1copy, w2copy, b2copy, xcopy)
-
-		a1copy := Clone(a1)
-		a2copy := Clone(a2)
-		//a2copy2 := Clone(a2)
-		z1copy := Clone(z1)
-		z2copy := Clone(z2)
-
-		dw1, db1, dw2, db2 := Back_prop(z1copy, a1copy, z2copy, a2copy, w1copy2, w2copy2, xcopy2, y)
-
-		dw1copy := Clone(dw1)
-		db1copy := Clone(db1)
-		dw2copy := Clone(dw2)
-		db2copy := Clone(db2)
-
-		w1, b1, w2, b2 = UpdateParameters(w1copy3, b1copy3, w2copy3, b2copy3, dw1copy, db1copy, dw2copy, db2copy, learningRate)
+// settings for a training run
+type TrainOptions struct {
+	Optimizer Optimizer  // turns each step's gradients into updates; must not be shared between networks
+	Epochs    int        // passes over the whole training set
+	BatchSize int        // samples per step; 0 (or anything >= the number of samples) means full batch
+	Rand      *rand.Rand // reshuffles the samples every epoch; nil keeps them in order
+	// called after every epoch with its number (from 0) and mean training loss; may be nil
+	EpochLoss func(epoch int, loss float64)
+}
 
-		// if i%10 == 0 {
-		// 	fmt.Println("accuracy: ")
-		// 	fmt.Printf("%f", GetAccuracy(Argmax(a2copy2), y))
-		// 	fmt.Println("")
-		// }
+// forward prop => back prop => update params => repeat
+// trains net in place and returns it
+// every buffer is allocated once, in the workspace, and reused for every mini-batch of every epoch
+func GradientDescent(net *Network, x *Matrix, y []float64, options TrainOptions) *Network {
+	ws := NewWorkspace(net)
+	for epoch := 0; epoch < options.Epochs; epoch++ {
+		loss := ws.Epoch(x, y, options)
+		if options.EpochLoss != nil {
+			options.EpochLoss(epoch, loss)
+		}
 	}
-
-	return weightsAndBiases{w1, b1, w2, b2}
+	return net
 }
 
-func MakePredictions(x [][]float64, w1 [][]float64, b1 [][]float64, w2 [][]float64, b2 [][]float64) []float64 {
-	_, _, _, a2 := Forward_prop(w1, b1, w2, b2, x)
-	return Argmax(a2)
+func MakePredictions(x *Matrix, net *Network) []float64 {
+	_, as := net.Forward(x)
+	return as[len(as)-1].Argmax()
 }
 
-// this function averages all of our weights and biases and returns a new weightsAndBiases struct
-func AggregateResults(allWeightsAndBiases [](weightsAndBiases)) weightsAndBiases {
-
-	finalw1 := make([][]float64, 10)
-	for i := range finalw1 {
-		finalw1[i] = make([]float64, 784)
-	}
-	for i := 0; i < 10; i++ {
-		for j := 0; j < 784; j++ {
-			// for each weight and bias
-			for k := 0; k < len(allWeightsAndBiases); k++ {
-				finalw1[i][j] += allWeightsAndBiases[k].w1[i][j]
-			}
-			finalw1[i][j] /= float64(len(allWeightsAndBiases))
+// this function averages the weights and biases of all of our networks and returns a new Network
+// every network must have the same layer sizes
+func AggregateResults(networks []*Network) *Network {
+	average := networks[0].Clone()
+	for _, net := range networks[1:] {
+		if !sameSizes(net.Sizes, average.Sizes) {
+			panic("Cannot average networks with different layer sizes.")
+		}
+		for l := range average.Weights {
+			average.Weights[l].AddInto(net.Weights[l], average.Weights[l])
+			average.Biases[l].AddInto(net.Biases[l], average.Biases[l])
 		}
 	}
 
-	finalw2 := make([][]float64, 10)
-	for i := range finalw2 {
-		finalw2[i] = make([]float64, 10)
-	}
-	for i := 0; i < 10; i++ {
-		for j := 0; j < 10; j++ {
-			// for each weight and bias
-			for k := 0; k < len(allWeightsAndBiases); k++ {
-				finalw2[i][j] += allWeightsAndBiases[k].w2[i][j]
-			}
-			finalw2[i][j] /= float64(len(allWeightsAndBiases))
-		}
+	scale := 1.0 / float64(len(networks))
+	for l := range average.Weights {
+		average.Weights[l].ScalarMultiplyInto(scale, average.Weights[l])
+		average.Biases[l].ScalarMultiplyInto(scale, average.Biases[l])
 	}
+	return average
+}
 
-	finalb1 := make([][]float64, 10)
-	finalb2 := make([][]float64, 10)
-	for i := range finalb1 {
-		finalb1[i] = make([]float64, 1)
-		finalb2[i] = make([]float64, 1)
+func sameSizes(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
 	}
-	for i := 0; i < 10; i++ {
-		for k := 0; k < len(allWeightsAndBiases); k++ {
-			finalb1[i][0] += allWeightsAndBiases[k].b1[i][0]
-			finalb2[i][0] += allWeightsAndBiases[k].b2[i][0]
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
-		finalb1[i][0] /= float64(len(allWeightsAndBiases))
-		finalb2[i][0] /= float64(len(allWeightsAndBiases))
 	}
-
-	return weightsAndBiases{finalw1, finalb1, finalw2, finalb2}
+	return true
 }
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+)
+
+// an Optimizer turns gradients into updates of a network's weights and biases
+// optimizers keep per-parameter state (velocities, moment estimates), so every network being trained needs its own
+type Optimizer interface {
+	// updates the weights and biases of net in place given their gradients
+	Step(net *Network, dw []*Matrix, db []*Matrix)
+}
+
+// the optimizers that can be picked by name from Config / the CLI
+var OptimizerNames = []string{"sgd", "momentum", "nesterov", "rmsprop", "adam", "adamw"}
+
+// returns a fresh optimizer with the default hyperparameters for the given name
+func NewOptimizer(name string, learningRate float64) (Optimizer, error) {
+	switch name {
+	case "sgd", "":
+		return &SGD{LearningRate: learningRate}, nil
+	case "momentum":
+		return &Momentum{LearningRate: learningRate, Momentum: 0.9}, nil
+	case "nesterov":
+		return &Momentum{LearningRate: learningRate, Momentum: 0.9, Nesterov: true}, nil
+	case "rmsprop":
+		return &RMSProp{LearningRate: learningRate, Decay: 0.9, Epsilon: 1e-8}, nil
+	case "adam":
+		return &Adam{LearningRate: learningRate, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8}, nil
+	case "adamw":
+		return &Adam{LearningRate: learningRate, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8, WeightDecay: 0.01}, nil
+	}
+	return nil, fmt.Errorf("unknown optimizer %q (want one of %v)", name, OptimizerNames)
+}
+
+// plain stochastic gradient descent
+// p -= lr * g
+type SGD struct {
+	LearningRate float64
+}
+
+func (o *SGD) Step(net *Network, dw []*Matrix, db []*Matrix) {
+	net.Update(dw, db, o.LearningRate)
+}
+
+// SGD with (optionally Nesterov) momentum
+// v = mu * v + g
+// p -= lr * v                  (classical)
+// p -= lr * (g + mu * v)       (Nesterov)
+type Momentum struct {
+	LearningRate float64
+	Momentum     float64
+	Nesterov     bool
+	velocity     []*Matrix
+}
+
+func (o *Momentum) Step(net *Network, dw []*Matrix, db []*Matrix) {
+	if o.velocity == nil {
+		o.velocity = zerosLike(net)
+	}
+	for k := 0; k < parameterCount(net); k++ {
+		params, grads := parameter(net, dw, db, k)
+		for i := 0; i < params.Rows; i++ {
+			param, grad, velocity := params.Row(i), grads.Row(i), o.velocity[k].Row(i)
+			for j, g := range grad {
+				v := o.Momentum*velocity[j] + g
+				velocity[j] = v
+				if o.Nesterov {
+					param[j] -= o.LearningRate * (g + o.Momentum*v)
+				} else {
+					param[j] -= o.LearningRate * v
+				}
+			}
+		}
+	}
+}
+
+// scales every step by a running average of the squared gradients
+// s = rho * s + (1 - rho) * g^2
+// p -= lr * g / (sqrt(s) + eps)
+type RMSProp struct {
+	LearningRate float64
+	Decay        float64 // rho
+	Epsilon      float64
+	meanSquare   []*Matrix
+}
+
+func (o *RMSProp) Step(net *Network, dw []*Matrix, db []*Matrix) {
+	if o.meanSquare == nil {
+		o.meanSquare = zerosLike(net)
+	}
+	for k := 0; k < parameterCount(net); k++ {
+		params, grads := parameter(net, dw, db, k)
+		for i := 0; i < params.Rows; i++ {
+			param, grad, meanSquare := params.Row(i), grads.Row(i), o.meanSquare[k].Row(i)
+			for j, g := range grad {
+				meanSquare[j] = o.Decay*meanSquare[j] + (1-o.Decay)*g*g
+				param[j] -= o.LearningRate * g / (math.Sqrt(meanSquare[j]) + o.Epsilon)
+			}
+		}
+	}
+}
+
+// Adam, or AdamW when WeightDecay is set
+// t += 1
+// m = b1 * m + (1 - b1) * g
+// v = b2 * v + (1 - b2) * g^2
+// p -= lr * (m / (1 - b1^t)) / (sqrt(v / (1 - b2^t)) + eps)
+// AdamW first shrinks the weights (not the biases) directly: p -= lr * decay * p
+type Adam struct {
+	LearningRate float64
+	Beta1        float64
+	Beta2        float64
+	Epsilon      float64
+	WeightDecay  float64 // decoupled weight decay; 0 is plain Adam
+	step         int
+	mean         []*Matrix
+	variance     []*Matrix
+}
+
+func (o *Adam) Step(net *Network, dw []*Matrix, db []*Matrix) {
+	if o.mean == nil {
+		o.mean = zerosLike(net)
+		o.variance = zerosLike(net)
+	}
+	o.step++
+	correction1 := 1 - math.Pow(o.Beta1, float64(o.step))
+	correction2 := 1 - math.Pow(o.Beta2, float64(o.step))
+
+	for k := 0; k < parameterCount(net); k++ {
+		params, grads := parameter(net, dw, db, k)
+		isWeight := k%2 == 0 // parameters alternate weights, biases
+		for i := 0; i < params.Rows; i++ {
+			param, grad := params.Row(i), grads.Row(i)
+			mean, variance := o.mean[k].Row(i), o.variance[k].Row(i)
+			for j, g := range grad {
+				if isWeight && o.WeightDecay != 0 {
+					param[j] -= o.LearningRate * o.WeightDecay * param[j]
+				}
+				mean[j] = o.Beta1*mean[j] + (1-o.Beta1)*g
+				variance[j] = o.Beta2*variance[j] + (1-o.Beta2)*g*g
+				param[j] -= o.LearningRate * (mean[j] / correction1) / (math.Sqrt(variance[j]/correction2) + o.Epsilon)
+			}
+		}
+	}
+}
+
+// the number of parameter matrices in net: a weight matrix and a bias vector per layer
+func parameterCount(net *Network) int {
+	return 2 * net.Layers()
+}
+
+// the k-th parameter of net and its gradient, in a fixed order: w0, b0, w1, b1, ...
+func parameter(net *Network, dw []*Matrix, db []*Matrix, k int) (*Matrix, *Matrix) {
+	l := k / 2
+	if k%2 == 0 {
+		return net.Weights[l], dw[l]
+	}
+	return net.Biases[l], db[l]
+}
+
+// zeroed matrices with the same shapes as the parameters of net, in the same order
+func zerosLike(net *Network) []*Matrix {
+	zeros := make([]*Matrix, 0, parameterCount(net))
+	for l := range net.Weights {
+		zeros = append(zeros, NewMatrix(net.Weights[l].Rows, net.Weights[l].Cols), NewMatrix(net.Biases[l].Rows, 1))
+	}
+	return zeros
+}
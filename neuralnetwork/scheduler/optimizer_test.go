@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+)
+
+// every optimizer takes two steps on a 1 -> 1 network with weight 1 and bias 0.5
+// the weight's gradient is 0.5 and then -0.25 (0.5 twice for the momentum optimizers), the bias's is -1 both times
+// the expected values are worked out by hand from the update rules in optimizer.go
+type optimizerCase struct {
+	name            string
+	optimizer       Optimizer
+	weightGradients [2]float64
+	weights         [2]float64 // after each step
+	biases          [2]float64
+}
+
+var optimizerCases = []optimizerCase{
+	{
+		// p -= lr * g
+		name:            "sgd",
+		optimizer:       &SGD{LearningRate: 0.1},
+		weightGradients: [2]float64{0.5, -0.25},
+		weights:         [2]float64{1 - 0.1*0.5, 0.95 + 0.1*0.25},
+		biases:          [2]float64{0.5 + 0.1, 0.6 + 0.1},
+	},
+	{
+		// v = 0.5, then 0.9 * 0.5 + 0.5 = 0.95; the bias's v = -1, then -1.9
+		name:            "momentum",
+		optimizer:       &Momentum{LearningRate: 0.1, Momentum: 0.9},
+		weightGradients: [2]float64{0.5, 0.5},
+		weights:         [2]float64{1 - 0.1*0.5, 0.95 - 0.1*0.95},
+		biases:          [2]float64{0.5 + 0.1, 0.6 + 0.1*1.9},
+	},
+	{
+		// same velocities, but the step is g + 0.9 * v: 0.5 + 0.45, then 0.5 + 0.855; the bias's -1.9, then -2.71
+		name:            "nesterov",
+		optimizer:       &Momentum{LearningRate: 0.1, Momentum: 0.9, Nesterov: true},
+		weightGradients: [2]float64{0.5, 0.5},
+		weights:         [2]float64{1 - 0.1*0.95, 0.905 - 0.1*1.355},
+		biases:          [2]float64{0.5 + 0.1*1.9, 0.69 + 0.1*2.71},
+	},
+	{
+		// s = 0.1 * 0.25 = 0.025, then 0.9 * 0.025 + 0.1 * 0.0625 = 0.02875
+		// the bias's s = 0.1, then 0.19
+		name:            "rmsprop",
+		optimizer:       &RMSProp{LearningRate: 0.1, Decay: 0.9, Epsilon: 1e-8},
+		weightGradients: [2]float64{0.5, -0.25},
+		weights:         [2]float64{1 - 0.1*0.5/math.Sqrt(0.025), 1 - 0.1*0.5/math.Sqrt(0.025) + 0.1*0.25/math.Sqrt(0.02875)},
+		biases:          [2]float64{0.5 + 0.1/math.Sqrt(0.1), 0.5 + 0.1/math.Sqrt(0.1) + 0.1/math.Sqrt(0.19)},
+	},
+	{
+		// step 1: m = 0.05, v = 0.00025; bias corrected they are 0.5 and 0.25, so the step is lr * 0.5 / 0.5 = 0.1
+		// (without the correction it would be 0.1 * 0.05 / sqrt(0.00025) = 0.316)
+		// step 2: m = 0.045 - 0.025 = 0.02, v = 0.00024975 + 0.0000625 = 0.00031225
+		// corrected by 1 - 0.9^2 = 0.19 and 1 - 0.999^2 = 0.001999: the step is 0.1 * (0.02 / 0.19) / sqrt(0.00031225 / 0.001999)
+		// the bias's gradient doesn't change, so both of its steps are lr
+		name:            "adam",
+		optimizer:       &Adam{LearningRate: 0.1, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8},
+		weightGradients: [2]float64{0.5, -0.25},
+		weights:         [2]float64{0.9, 0.9 - 0.1*(0.02/0.19)/math.Sqrt(0.00031225/0.001999)},
+		biases:          [2]float64{0.6, 0.7},
+	},
+	{
+		// as adam, but the weight first shrinks by lr * decay = 0.1%: 1 -> 0.999 -> 0.899, then 0.899 -> 0.898101
+		// the bias doesn't decay
+		name:            "adamw",
+		optimizer:       &Adam{LearningRate: 0.1, Beta1: 0.9, Beta2: 0.999, Epsilon: 1e-8, WeightDecay: 0.01},
+		weightGradients: [2]float64{0.5, -0.25},
+		weights:         [2]float64{0.999 - 0.1, 0.899*0.999 - 0.1*(0.02/0.19)/math.Sqrt(0.00031225/0.001999)},
+		biases:          [2]float64{0.6, 0.7},
+	},
+}
+
+func TestOptimizerSteps(t *testing.T) {
+	for _, c := range optimizerCases {
+		net := &Network{
+			Sizes:   []int{1, 1},
+			Weights: []*Matrix{MatrixFromSlices([][]float64{{1}})},
+			Biases:  []*Matrix{MatrixFromSlices([][]float64{{0.5}})},
+		}
+		for step := 0; step < 2; step++ {
+			dw := []*Matrix{MatrixFromSlices([][]float64{{c.weightGradients[step]}})}
+			db := []*Matrix{MatrixFromSlices([][]float64{{-1}})}
+			c.optimizer.Step(net, dw, db)
+			// epsilon moves the adaptive optimizers' steps by about 1e-8 relative to the exact values above
+			if w := net.Weights[0].At(0, 0); math.Abs(w-c.weights[step]) > 1e-7 {
+				t.Errorf("%s step %d: weight %.10f, want %.10f", c.name, step+1, w, c.weights[step])
+			}
+			if b := net.Biases[0].At(0, 0); math.Abs(b-c.biases[step]) > 1e-7 {
+				t.Errorf("%s step %d: bias %.10f, want %.10f", c.name, step+1, b, c.biases[step])
+			}
+		}
+	}
+}
+
+func TestNewOptimizer(t *testing.T) {
+	for _, name := range OptimizerNames {
+		if _, err := NewOptimizer(name, 0.1); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := NewOptimizer("lbfgs", 0.1); err == nil {
+		t.Error("unknown optimizer accepted")
+	}
+}
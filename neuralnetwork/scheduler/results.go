@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// a ResultSink has one fixed slot per training chunk, indexed by the chunk id
+// every chunk only ever writes its own slot, so the results themselves need no lock
+// and the order they are aggregated in doesn't depend on which executor ran them
+type ResultSink struct {
+	slots  []*Network
+	filled []int32 // set to 1 (atomically) once a slot has been written
+}
+
+func NewResultSink(size int) *ResultSink {
+	return &ResultSink{
+		slots:  make([]*Network, size),
+		filled: make([]int32, size),
+	}
+}
+
+// stores the result for chunk id
+// errors if the id has no slot or the slot was already written
+func (sink *ResultSink) Put(id int, result *Network) error {
+	if id < 0 || id >= len(sink.slots) {
+		return fmt.Errorf("result sink: chunk id %d out of range [0, %d)", id, len(sink.slots))
+	}
+	if !atomic.CompareAndSwapInt32(&sink.filled[id], 0, 1) {
+		return fmt.Errorf("result sink: chunk %d reported more than once", id)
+	}
+	sink.slots[id] = result
+	return nil
+}
+
+// returns every result in chunk id order
+// must only be called once all of the chunks are done; errors if any chunk is missing
+func (sink *ResultSink) Results() ([]*Network, error) {
+	for i := range sink.filled {
+		if atomic.LoadInt32(&sink.filled[i]) == 0 {
+			return nil, fmt.Errorf("result sink: missing result for chunk %d", i)
+		}
+	}
+	return sink.slots, nil
+}
@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"math/rand"
+	"testing"
+
+	"proj3/concurrent"
+)
+
+// a small labelled data set: every class is a gaussian blob around its own center
+type syntheticDataset struct {
+	features, classes int
+	train, test       Split
+}
+
+func newSyntheticDataset(features, classes, trainSamples, testSamples int, seed int64) *syntheticDataset {
+	rng := rand.New(rand.NewSource(seed))
+	centers := NewMatrix(features, classes)
+	fillNormal(centers, 2, rng)
+	split := func(samples int) Split {
+		x := NewMatrix(features, samples)
+		y := make([]float64, samples)
+		for j := range y {
+			class := rng.Intn(classes)
+			y[j] = float64(class)
+			for i := 0; i < features; i++ {
+				x.Set(i, j, centers.At(i, class)+0.3*rng.NormFloat64())
+			}
+		}
+		return Split{X: x, Y: y}
+	}
+	return &syntheticDataset{features: features, classes: classes, train: split(trainSamples), test: split(testSamples)}
+}
+
+func (data *syntheticDataset) Name() string  { return "synthetic" }
+func (data *syntheticDataset) Features() int { return data.features }
+func (data *syntheticDataset) Classes() int  { return data.classes }
+func (data *syntheticDataset) ClassNames() []string {
+	names := make([]string, data.classes)
+	for i := range names {
+		names[i] = string(rune('a' + i))
+	}
+	return names
+}
+func (data *syntheticDataset) Train() Split { return data.train }
+func (data *syntheticDataset) Test() Split  { return data.test }
+
+// a config that trains data in the given mode with the default chunking
+func syntheticConfig(data Dataset, mode string, threads int) Config {
+	return Config{
+		Mode:         mode,
+		ThreadCount:  threads,
+		Epochs:       2,
+		Layers:       []int{data.Features(), 8, data.Classes()},
+		Init:         "he",
+		LearningRate: 0.1,
+		Seed:         1,
+		Combine:      "weights",
+	}
+}
+
+func TestTrainRoundFillsEverySlot(t *testing.T) {
+	// 60000 samples split into the default 60 chunks of DefaultChunkSize, as on MNIST
+	data := newSyntheticDataset(4, 3, 60*DefaultChunkSize, 100, 1)
+	executors := map[string]func() concurrent.ExecutorService{
+		"ws": func() concurrent.ExecutorService { return concurrent.NewWorkStealingExecutor(4, 10) },
+		"wb": func() concurrent.ExecutorService { return concurrent.NewWorkBalancingExecutor(4, 10, 10) },
+	}
+	for mode, newExecutor := range executors {
+		t.Run(mode, func(t *testing.T) {
+			config := syntheticConfig(data, mode, 4)
+			bounds, err := ChunkBounds(data.Train().Samples(), config.Chunks, config.ChunkSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bounds) != 60 {
+				t.Fatalf("default chunking gave %d chunks, want 60", len(bounds))
+			}
+			xChunks, yChunks := SplitChunks(data.Train().X, data.Train().Y, bounds)
+			options := make([]TrainOptions, len(bounds))
+			networks := make([]*Network, len(bounds))
+			for i := range bounds {
+				if options[i], err = trainOptions(config, i); err != nil {
+					t.Fatal(err)
+				}
+				if networks[i], err = startingNetwork(config, nil, options[i].Rand); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			executor := newExecutor()
+			trained, err := trainRound(executor, networks, xChunks, yChunks, options, config.Epochs)
+			executor.Shutdown()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(trained) != 60 {
+				t.Fatalf("aggregated %d models, want 60", len(trained))
+			}
+			seen := make(map[*Network]bool)
+			for i, net := range trained {
+				if net == nil {
+					t.Fatalf("model %d is nil", i)
+				}
+				if seen[net] {
+					t.Fatalf("model %d was reported for more than one chunk", i)
+				}
+				seen[net] = true
+			}
+		})
+	}
+}
+
+func TestRunParallelAggregatesEveryChunk(t *testing.T) {
+	data := newSyntheticDataset(4, 3, 60*DefaultChunkSize, 300, 2)
+	for _, mode := range []string{"ws", "wb"} {
+		t.Run(mode, func(t *testing.T) {
+			config := syntheticConfig(data, mode, 4)
+			config.BatchSize = 100
+			config.SyncEvery = 1 // averaging independently initialized networks would learn nothing
+			network, report, err := runParallel(config, data, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if network == nil {
+				t.Fatal("no averaged network")
+			}
+			if len(report.TrainingLoss) != config.Epochs {
+				t.Errorf("%d training losses for %d epochs", len(report.TrainingLoss), config.Epochs)
+			}
+			if report.Accuracy < 0.9 {
+				t.Errorf("accuracy %.3f on separable blobs, want at least 0.9", report.Accuracy)
+			}
+		})
+	}
+}
+
+func TestResultSinkRejectsBadPuts(t *testing.T) {
+	sink := NewResultSink(3)
+	net := &Network{}
+	for _, id := range []int{-1, 3, 100} {
+		if err := sink.Put(id, net); err == nil {
+			t.Errorf("Put(%d) on 3 slots succeeded", id)
+		}
+	}
+	if err := sink.Put(1, net); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Put(1, net); err == nil {
+		t.Error("second Put for chunk 1 succeeded")
+	}
+	if _, err := sink.Results(); err == nil {
+		t.Error("Results succeeded with chunks 0 and 2 missing")
+	}
+	sink.Put(0, net)
+	sink.Put(2, net)
+	results, err := sink.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Errorf("got %d results, want 3", len(results))
+	}
+}
+
+func TestResultSinkConcurrentPuts(t *testing.T) {
+	const slots = 64
+	sink := NewResultSink(slots)
+	errs := make(chan error, 2*slots)
+	for i := 0; i < slots; i++ {
+		for k := 0; k < 2; k++ { // every chunk reports twice at the same time; exactly one must win
+			go func(id int) { errs <- sink.Put(id, &Network{}) }(i)
+		}
+	}
+	failed := 0
+	for i := 0; i < 2*slots; i++ {
+		if <-errs != nil {
+			failed++
+		}
+	}
+	if failed != slots {
+		t.Errorf("%d of %d duplicate Puts failed, want %d", failed, 2*slots, slots)
+	}
+	if _, err := sink.Results(); err != nil {
+		t.Error(err)
+	}
+}
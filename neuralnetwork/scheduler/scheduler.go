@@ -1,115 +1,412 @@
 package scheduler
 
 import (
+	"fmt"
+	"math/rand"
 	"proj3/concurrent"
+	"time"
 )
 
 type Config struct {
 	Mode string // Represents which scheduler scheme to use
 	// If Mode == "s" run the sequential version
-	// If Mode == "p" run the parallel version
+	// If Mode == "ws" or "wb" run the parallel ensemble version with work stealing or work balancing
+	// If Mode == "dp" run the synchronous data parallel version
 	// These are the only values for Version
 	ThreadCount int // Runs the parallel version of the program with the
 	// specified number of threads (i.e., goroutines)
-	Epochs int // The number of epochs to run the neural network for
+	Epochs int   // The number of epochs to run the neural network for
+	Layers []int // The size of every layer, input first (e.g. 784,128,64,10); the first and last must match the data set
+	// If nil, the data set's sizes around DefaultHiddenLayers are used (or the checkpoint's sizes when Load is set)
+	Activations []string // The activation of every hidden layer, or a single one for all of them (see ActivationNames)
+	// If nil, every hidden layer uses relu (or the checkpoint's activations when Load is set)
+	Dataset string // One of DatasetNames; "" means mnist
+	DataDir string // The directory the data files are in; "" means $MNIST_DIR, or DefaultDataDir if that isn't set
+	Load    string // Path of a checkpoint to start training from instead of a random network
+	// With 0 epochs the checkpoint is only evaluated
+	Save      string // Path to write the trained network to; "" means don't save
+	BatchSize int    // Samples per gradient step; 0 means full batch (one step per epoch)
+	Seed      int64  // Seeds the initial weights and the shuffling of mini-batches; 0 picks a seed from the clock
+	// Every model draws from its own source seeded with Seed + its chunk id, so a run is reproducible bit for bit
+	Init         string  // One of InitNames, how new networks are initialized; "" means uniform
+	Optimizer    string  // One of OptimizerNames; "" means sgd
+	LearningRate float64 // 0 means 0.1
+	SyncEvery    int     // ws/wb only: average the chunks' networks every SyncEvery epochs, starting them all
+	// from the same initial network; 0 trains independent networks and averages once at the end
+	Combine string // ws/wb only: one of CombineNames, how the ensemble predicts on the test set; "" means weights
+	Compare bool   // ws/wb only: also report the test accuracy of every combination method
+	Chunks  int    // ws/wb only: the number of chunks (ensemble members) the training set is split into
+	// ChunkSize: ws/wb only: samples per chunk; see ChunkBounds for how it combines with Chunks
+	// with neither set the chunks hold DefaultChunkSize samples each
+	ChunkSize int
+	Stratify  bool // ws/wb only: reorder the training set first so every chunk has the same class balance
+	// GEMMThreads: goroutines every large matrix multiply is split across (see SetGEMMThreads); 0 or 1 means none
+	// most useful in s and dp modes, where there are fewer tasks than cores
+	GEMMThreads int
+	// Idle: one of concurrent.IdleStrategyNames, what the executors' (and the GEMM pool's) workers do while
+	// they have no task: spin for the lowest latency, park to use no CPU; "" means default (spin, back off, then park)
+	Idle string
 }
 
+// the values Config.Mode can take
+var ModeNames = []string{"s", "ws", "wb", "dp"}
+
 // Run the correct version based on the Mode field of the configuration value
-func Schedule(config Config) {
+// returns the evaluation of the trained network on the test set
+// invalid settings, missing data files and unreadable checkpoints are returned as errors
+func Schedule(config Config) (*Report, error) {
+	if !contains(ModeNames, config.Mode) {
+		return nil, fmt.Errorf("invalid scheduling scheme %q (want one of %v)", config.Mode, ModeNames)
+	}
+	if config.Mode != "s" && config.ThreadCount < 1 {
+		return nil, fmt.Errorf("%s needs at least 1 thread (got %d)", config.Mode, config.ThreadCount)
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if config.LearningRate == 0 {
+		config.LearningRate = 0.1
+	}
+	if _, err := NewOptimizer(config.Optimizer, config.LearningRate); err != nil {
+		return nil, err
+	}
+	if _, err := NewInitializer(config.Init); err != nil {
+		return nil, err
+	}
+	if _, err := concurrent.FindIdleStrategy(config.Idle); err != nil {
+		return nil, err
+	}
+	if config.Combine == "" {
+		config.Combine = "weights"
+	}
+	if !contains(CombineNames, config.Combine) {
+		return nil, fmt.Errorf("invalid combination method %q (want one of %v)", config.Combine, CombineNames)
+	}
+	preset, err := FindPreset(config.Dataset)
+	if err != nil {
+		return nil, err
+	}
+	var start *Network
+	if config.Load != "" {
+		loaded, err := LoadModel(config.Load)
+		if err != nil {
+			return nil, fmt.Errorf("can't load the model: %w", err)
+		}
+		if config.Layers != nil && !sameSizes(config.Layers, loaded.Sizes) {
+			return nil, fmt.Errorf("layer sizes %v don't match the loaded checkpoint's %v", config.Layers, loaded.Sizes)
+		}
+		if config.Activations != nil && !sameActivations(config.Activations, loaded) {
+			return nil, fmt.Errorf("activations %v don't match the loaded checkpoint's %v", config.Activations, activationNames(loaded.Activations))
+		}
+		config.Layers = loaded.Sizes
+		config.Activations = activationNames(loaded.Activations)
+		start = loaded
+	}
+	if config.Layers == nil {
+		config.Layers = append(append([]int{preset.Features()}, DefaultHiddenLayers...), len(preset.Classes))
+	}
+	if len(config.Layers) < 2 || config.Layers[0] != preset.Features() || config.Layers[len(config.Layers)-1] != len(preset.Classes) {
+		return nil, fmt.Errorf("invalid layer sizes %v: %s needs %d inputs and %d outputs", config.Layers, preset.Name, preset.Features(), len(preset.Classes))
+	}
+	if _, err := NewActivations(config.Activations, len(config.Layers)-2); err != nil {
+		return nil, err
+	}
+
+	data, err := LoadData(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Mode == "ws" || config.Mode == "wb" {
+		if _, err := ChunkBounds(data.Train().Samples(), config.Chunks, config.ChunkSize); err != nil {
+			return nil, err
+		}
+	}
+
+	idle, err := idleOption(config)
+	if err != nil {
+		return nil, err
+	}
+	SetGEMMThreads(config.GEMMThreads, idle)
+	defer SetGEMMThreads(1)
+
+	var network *Network
+	var report *Report
 	if config.Mode == "s" {
-		RunSequential(config)
+		network, report, err = runSequential(config, data, start)
 	} else if config.Mode == "ws" || config.Mode == "wb" {
-		RunParallel(config)
+		network, report, err = runParallel(config, data, start)
 	} else {
-		panic("Invalid scheduling scheme given.")
+		network, report, err = runDataParallel(config, data, start)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report.Dataset = data.Name()
+	report.NameClasses(data.ClassNames())
+
+	if config.Save != "" {
+		if err := SaveModel(config.Save, network); err != nil {
+			return nil, fmt.Errorf("can't save the model: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// returns the network a training run starts from:
+// a copy of the loaded checkpoint if there is one, otherwise a network freshly initialized from rng
+func startingNetwork(config Config, start *Network, rng *rand.Rand) (*Network, error) {
+	if start != nil {
+		return start.Clone(), nil
+	}
+	init, err := NewInitializer(config.Init)
+	if err != nil {
+		return nil, err
+	}
+	net := NewNetwork(config.Layers, init, rng)
+	activations, err := NewActivations(config.Activations, net.Layers()-1)
+	if err != nil {
+		return nil, err
+	}
+	net.Activations = activations
+	return net, nil
+}
+
+// whether the activation names given in a config pick the same activations as net has
+func sameActivations(names []string, net *Network) bool {
+	activations, err := NewActivations(names, len(net.Activations))
+	if err != nil {
+		return false
+	}
+	for l, activation := range activations {
+		if activation.Name() != net.Activations[l].Name() {
+			return false
+		}
 	}
+	return true
 }
 
-// we only need to have one global array to store all of our results
-type SharedContext struct {
-	AllWeightsAndBiases [](weightsAndBiases)
+// the executor option for the idle strategy the config asks for
+func idleOption(config Config) (concurrent.ExecutorOption, error) {
+	strategy, err := concurrent.FindIdleStrategy(config.Idle)
+	if err != nil {
+		return nil, err
+	}
+	return concurrent.WithIdleStrategy(strategy), nil
 }
 
-// a TrainingBatch consists of a shared context, a batch of training data, and a batch of training labels
+// the training settings for one model
+// each model gets its own optimizer state and its own random source (seed + id) so parallel tasks never share one
+// the source initializes the model's network (startingNetwork) before it shuffles its mini-batches
+func trainOptions(config Config, id int) (TrainOptions, error) {
+	optimizer, err := NewOptimizer(config.Optimizer, config.LearningRate)
+	if err != nil {
+		return TrainOptions{}, err
+	}
+	return TrainOptions{
+		Optimizer: optimizer,
+		Epochs:    config.Epochs,
+		BatchSize: config.BatchSize,
+		Rand:      rand.New(rand.NewSource(config.Seed + int64(id))),
+	}, nil
+}
+
+// a TrainingBatch consists of the result sink, the network to train, a batch of training data, and a batch of training labels
 type TrainingBatch struct {
-	ctx    *SharedContext
-	xTrain [][]float64
-	yTrain []float64
-	id     int
-	epochs int
+	sink    *ResultSink
+	network *Network
+	xTrain  *Matrix
+	yTrain  []float64
+	id      int
+	options TrainOptions
 }
 
-func NewSharedContext(ctx *SharedContext, xTrain [][]float64, yTrain []float64, id int, epochs int) concurrent.Runnable {
-	return &TrainingBatch{ctx, xTrain, yTrain, id, epochs}
+func NewTrainingBatch(sink *ResultSink, network *Network, xTrain *Matrix, yTrain []float64, id int, options TrainOptions) concurrent.Callable {
+	return &TrainingBatch{sink, network, xTrain, yTrain, id, options}
 }
 
-// our neural network will have 784 input nodes, 1 hidden layer with 10 nodes, and 10 output nodes (one for each digit)
+// our neural network has an input node per pixel (784), any number of hidden layers (ReLU unless Config.Activations says otherwise),
+// and an output node per class of the data set (10 for the digits of MNIST)
+// by default there is 1 hidden layer with 10 nodes
 // extensively referenced https://www.youtube.com/watch?v=w8yWXqWQYmU for the general structure of the neural network
-func RunSequential(config Config) {
-	xTrain, yTrain, xTest, yTest := LoadData(config)
+// start is the loaded checkpoint to continue training from, or nil
+// config must have been completed and checked by Schedule
+func runSequential(config Config, data Dataset, start *Network) (*Network, *Report, error) {
+	xTrain, yTrain := data.Train().X, data.Train().Y
+	xTest, yTest := data.Test().X, data.Test().Y
 
-	weightsAndBiases := GradientDescent(xTrain, yTrain, 0.1, config.Epochs) // returns final weights and biases
+	var losses []float64
+	options, err := trainOptions(config, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	options = recordLoss(options, &losses)
+	network, err := startingNetwork(config, start, options.Rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	network = GradientDescent(network, xTrain, yTrain, options) // returns the trained network
 
-	// generates accuracy for test data
-	// testPredictions := MakePredictions(xTest, weightsAndBiases.w1, weightsAndBiases.b1, weightsAndBiases.w2, weightsAndBiases.b2)
-	// fmt.Println("test accuracy: ")
-	// fmt.Println(GetAccuracy(testPredictions, yTest))
-	GetAccuracy(MakePredictions(xTest, weightsAndBiases.w1, weightsAndBiases.b1, weightsAndBiases.w2, weightsAndBiases.b2), yTest)
+	// generates accuracy, loss and per-class metrics for test data
+	report := Evaluate(network, xTest, yTest)
+	report.TrainingLoss = losses
+	report.Seed = config.Seed
+	return network, report, nil
 }
 
 // runs gradient descent on a batch of training data
-// adds the final weights and biases to the shared context
+// stores the final weights and biases in this batch's slot of the result sink
+// the future yields the error from the sink (nil on success)
 // for parallel
-func (task *TrainingBatch) Run() {
-	weightsAndBiases := GradientDescent(task.xTrain, task.yTrain, 0.1, task.epochs) // returns final weights and biases for one training batch
-
-	// add the weights and biases from one training batch to the shared context
-	task.ctx.AllWeightsAndBiases = append(task.ctx.AllWeightsAndBiases, weightsAndBiases)
+func (task *TrainingBatch) Call() interface{} {
+	network := GradientDescent(task.network, task.xTrain, task.yTrain, task.options) // returns the trained network for one training batch
+	return task.sink.Put(task.id, network)
 }
 
-func RunParallel(config Config) {
-	xTrain, yTrain, xTest, yTest := LoadData(config)
+// start is the loaded checkpoint every chunk continues training from, or nil for random networks
+// config must have been completed and checked by Schedule
+func runParallel(config Config, data Dataset, start *Network) (*Network, *Report, error) {
+	xTrain, yTrain := data.Train().X, data.Train().Y
+	xTest, yTest := data.Test().X, data.Test().Y
 
 	// initialize executor and load it with tasks
 	// we use a form a data parallelism + ensemble learning
 	// in other words, we split up our training data, run each split through the neural network, and average the results
+	// with SyncEvery > 0 we average every SyncEvery epochs and keep training from the average (local SGD)
+
+	// split the training set into chunks (by default 60 chunks of 1000)
+	// the chunks are views into xTrain and yTrain, so no chunk copies any training data
+	if config.Stratify {
+		order := StratifiedOrder(yTrain) // one reordered copy of the whole set
+		xTrain, yTrain = xTrain.SelectColumns(order), SelectLabels(yTrain, order)
+	}
+	bounds, err := ChunkBounds(len(yTrain), config.Chunks, config.ChunkSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	xChunks, yChunks := SplitChunks(xTrain, yTrain, bounds)
+	chunks := len(bounds)
 
-	// initialize SharedContext with an empty global array of weights and biases, size is our number of threads
-	context := SharedContext{make([](weightsAndBiases), config.ThreadCount)}
+	// every chunk keeps its own optimizer state, random source and training losses for the whole run
+	options := make([]TrainOptions, chunks)
+	losses := make([][]float64, chunks)
+	networks := make([]*Network, chunks)
+	for i := 0; i < chunks; i++ {
+		if options[i], err = trainOptions(config, i); err != nil {
+			return nil, nil, err
+		}
+		options[i] = recordLoss(options[i], &losses[i])
+		if config.SyncEvery > 0 && i > 0 {
+			networks[i] = networks[0].Clone() // local SGD: everyone starts from the same parameters
+		} else if networks[i], err = startingNetwork(config, start, options[i].Rand); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// initialize executor
+	idle, err := idleOption(config)
+	if err != nil {
+		return nil, nil, err
+	}
 	var executor concurrent.ExecutorService
 	if config.Mode == "ws" {
-		executor = concurrent.NewWorkStealingExecutor(config.ThreadCount, 10)
-	} else if config.Mode == "wb" {
-		executor = concurrent.NewWorkBalancingExecutor(config.ThreadCount, 10, 10)
+		executor = concurrent.NewWorkStealingExecutor(config.ThreadCount, 10, idle)
+	} else {
+		executor = concurrent.NewWorkBalancingExecutor(config.ThreadCount, 10, 10, idle)
 	}
+	defer executor.Shutdown()
 
-	width := 1000
-	for i := 0; i < 60; i++ { // split training set into 60 chunks
-		chunkCeil := width * i
-		chunkFloor := chunkCeil + width
-
-		// https://stackoverflow.com/questions/54507818/selecting-2d-sub-slice-of-a-2d-slice-using-ranges-in-go
-		xTraincopy := Clone(xTrain)
-		b := xTraincopy[0:784]
-		for i := range b {
-			b[i] = b[i][chunkCeil:chunkFloor]
+	var ensemble *Ensemble
+	remaining := config.Epochs
+	for {
+		epochs := remaining
+		if config.SyncEvery > 0 && epochs > config.SyncEvery {
+			epochs = config.SyncEvery
+		}
+		members, err := trainRound(executor, networks, xChunks, yChunks, options, epochs)
+		if err != nil {
+			return nil, nil, err
+		}
+		ensemble = &Ensemble{Members: members}
+		remaining -= epochs
+		if remaining <= 0 {
+			break
 		}
+		average := ensemble.Average()
+		for i := range networks {
+			networks[i] = average.Clone() // continue training from the average
+		}
+	}
 
+	// generates accuracy, loss and per-class metrics for test data
+	// the ensemble predicts with the configured combination method; the saved network is always the weight average
+	report := ensemble.Evaluate(executor, config.Combine, xTest, yTest)
+	report.TrainingLoss = averageLosses(losses, yChunks)
+	report.Combine = config.Combine
+	report.Seed = config.Seed
+	if config.Compare {
+		report.Combinations = ensemble.Compare(executor, xTest, yTest)
+	}
+	return ensemble.Average(), report, nil
+}
+
+// trains every chunk's network for the given number of epochs on the executor
+// and returns the trained networks in chunk order, or the first error a chunk's task reported
+func trainRound(executor concurrent.ExecutorService, networks []*Network, xChunks []*Matrix, yChunks [][]float64, options []TrainOptions, epochs int) ([]*Network, error) {
+	chunks := len(networks)
+	sink := NewResultSink(chunks) // one slot per chunk
+	futures := make([]concurrent.Future, chunks)
+	for i := 0; i < chunks; i++ {
 		// submit each chunk to the executor
-		// we're sending a pointer to the shared context, the training and test data, and the id of the chunk
-		// we send the id so that we can distribute the tasks evenly across threads
-		executor.Submit(NewSharedContext(&context, b, yTrain[chunkCeil:chunkFloor], i, config.Epochs))
-	}
-	// blocks until all tasks are complete
-	executor.Shutdown()
-	// averages the weights and biases from all of the training batches
-	weightsAndBiases := AggregateResults(context.AllWeightsAndBiases[config.ThreadCount:])
-
-	// generates accuracy for test data
-	// testPredictions := MakePredictions(xTest, weightsAndBiases.w1, weightsAndBiases.b1, weightsAndBiases.w2, weightsAndBiases.b2)
-	// fmt.Println("test accuracy: ")
-	// fmt.Println(GetAccuracy(testPredictions, yTest))
-	GetAccuracy(MakePredictions(xTest, weightsAndBiases.w1, weightsAndBiases.b1, weightsAndBiases.w2, weightsAndBiases.b2), yTest)
+		// we're sending the result sink, the chunk's own network, the training data, and the id of the chunk
+		// the id picks the slot in the sink that the chunk's weights and biases land in
+		chunkOptions := options[i]
+		chunkOptions.Epochs = epochs
+		futures[i] = executor.Submit(NewTrainingBatch(sink, networks[i], xChunks[i], yChunks[i], i, chunkOptions))
+	}
+
+	// each future blocks until its chunk has been trained; all of them are waited for before returning
+	var firstErr error
+	for _, future := range futures {
+		if err, _ := future.Get().(error); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return sink.Results()
+}
+
+// makes options append the training loss of every epoch to losses
+func recordLoss(options TrainOptions, losses *[]float64) TrainOptions {
+	options.EpochLoss = func(epoch int, loss float64) {
+		*losses = append(*losses, loss)
+	}
+	return options
+}
+
+// the training loss of the whole ensemble in every epoch: the chunks' losses weighted by chunk size
+func averageLosses(losses [][]float64, yChunks [][]float64) []float64 {
+	average := make([]float64, len(losses[0]))
+	samples := 0
+	for i := range losses {
+		for epoch, loss := range losses[i] {
+			average[epoch] += loss * float64(len(yChunks[i]))
+		}
+		samples += len(yChunks[i])
+	}
+	for epoch := range average {
+		average[epoch] /= float64(samples)
+	}
+	return average
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
 }
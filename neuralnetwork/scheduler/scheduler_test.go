@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"proj3/concurrent"
+	"proj3/idx"
+	"proj3/mnist"
+)
+
+// writes an MNIST-shaped data set of random images, labelled 0 to 9 in turn, into dir
+func writeMNIST(t *testing.T, dir string, train, test int) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	write := func(imageName, labelName string, samples int) {
+		images, err := idx.NewArray(idx.Ubyte, samples, mnist.Height, mnist.Width)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pixels := images.Data.([]uint8)
+		for i := range pixels {
+			pixels[i] = uint8(rng.Intn(256))
+		}
+		labels, err := idx.NewArray(idx.Ubyte, samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range labels.Data.([]uint8) {
+			labels.Data.([]uint8)[i] = uint8(i % 10)
+		}
+		if err := idx.WriteFile(filepath.Join(dir, imageName), images); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.WriteFile(filepath.Join(dir, labelName), labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(mnist.TrainingImageFileName, mnist.TrainingLabelFileName, train)
+	write(mnist.TestImageFileName, mnist.TestLabelFileName, test)
+}
+
+// settings that used to crash the program must come back from Schedule as errors
+func TestScheduleRejectsInvalidSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeMNIST(t, dir, 3000, 100)
+	cases := []struct {
+		name   string
+		config Config
+	}{
+		{"dp with 0 threads", Config{Mode: "dp", Epochs: 1}},
+		{"ws with 0 threads", Config{Mode: "ws", Epochs: 1}},
+		{"wb with -1 threads", Config{Mode: "wb", Epochs: 1, ThreadCount: -1}},
+		{"more chunks than samples", Config{Mode: "ws", Epochs: 1, ThreadCount: 4, Chunks: 5000}},
+		{"chunks that don't fit", Config{Mode: "wb", Epochs: 1, ThreadCount: 4, Chunks: 4, ChunkSize: 1000}},
+		{"negative chunk size", Config{Mode: "ws", Epochs: 1, ThreadCount: 4, ChunkSize: -1}},
+	}
+	for _, c := range cases {
+		c.config.DataDir = dir
+		if _, err := Schedule(c.config); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+
+	// the same data directory trains fine with valid settings
+	report, err := Schedule(Config{Mode: "dp", Epochs: 1, ThreadCount: 2, DataDir: dir, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Samples != 100 {
+		t.Errorf("evaluated %d test samples, want 100", report.Samples)
+	}
+}
+
+// a mini-batch with no samples has no gradient; the network must be left alone
+func TestDataParallelStepEmptyBatch(t *testing.T) {
+	data := newSyntheticDataset(4, 3, 10, 10, 1)
+	config := syntheticConfig(data, "dp", 2)
+	options, err := trainOptions(config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net, err := startingNetwork(config, nil, options.Rand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := net.Clone()
+	executor := concurrent.NewWorkStealingExecutor(2, 10)
+	defer executor.Shutdown()
+
+	workspaces := []*Workspace{NewWorkspace(net), NewWorkspace(net)}
+	empty := data.Train().X.ColumnView(0, 0)
+	loss := dataParallelStep(executor, workspaces, net, empty, nil, &SGD{LearningRate: 0.1})
+	if loss != 0 {
+		t.Errorf("loss %v on an empty batch", loss)
+	}
+	loss = dataParallelStep(executor, nil, net, data.Train().X, data.Train().Y, &SGD{LearningRate: 0.1})
+	if loss != 0 {
+		t.Errorf("loss %v with no shards", loss)
+	}
+	for l := range net.Weights {
+		if !sameBitsMatrix(net.Weights[l], before.Weights[l]) || !sameBitsMatrix(net.Biases[l], before.Biases[l]) {
+			t.Fatalf("layer %d changed without any gradient", l)
+		}
+	}
+}
+
+// with a fixed seed every mode must train the same network bit for bit, however its tasks are scheduled
+func TestScheduleIsReproducible(t *testing.T) {
+	dir := t.TempDir()
+	writeMNIST(t, dir, 600, 50)
+	for _, mode := range ModeNames {
+		t.Run(mode, func(t *testing.T) {
+			var models [2]*Network
+			for run := range models {
+				save := filepath.Join(t.TempDir(), "model.bin")
+				config := Config{Mode: mode, ThreadCount: 3, Epochs: 2, DataDir: dir, Seed: 7, BatchSize: 50,
+					GEMMThreads: 2, Chunks: 3, SyncEvery: 1, Save: save}
+				if _, err := Schedule(config); err != nil {
+					t.Fatal(err)
+				}
+				model, err := LoadModel(save)
+				if err != nil {
+					t.Fatal(err)
+				}
+				models[run] = model
+			}
+			for l := range models[0].Weights {
+				if !sameBitsMatrix(models[0].Weights[l], models[1].Weights[l]) || !sameBitsMatrix(models[0].Biases[l], models[1].Biases[l]) {
+					t.Fatalf("layer %d differs between two runs with the same seed", l)
+				}
+			}
+		})
+	}
+}
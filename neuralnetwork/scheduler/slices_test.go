@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// the matrix operations on [][]float64, one slice per row, as they were before the Matrix type
+// they are only kept as the reference the Matrix benchmarks are measured against
+
+func legacyClone(arr [][]float64) [][]float64 {
+	res := make([][]float64, len(arr))
+	for i := range arr {
+		res[i] = append([]float64{}, arr[i]...)
+	}
+	return res
+}
+
+func legacyDot(a [][]float64, b [][]float64) [][]float64 {
+	aRows := len(a)
+	bRows := len(b)
+	bCols := len(b[0])
+
+	c := make([][]float64, aRows)
+	for i := range c {
+		c[i] = make([]float64, bCols)
+	}
+	for i := 0; i < aRows; i++ {
+		for j := 0; j < bCols; j++ {
+			sum := 0.0
+			for k := 0; k < bRows; k++ {
+				sum += a[i][k] * b[k][j]
+			}
+			c[i][j] = sum
+		}
+	}
+	return c
+}
+
+func legacySubtract(a [][]float64, b [][]float64) [][]float64 {
+	for i := range a {
+		for j := range a[i] {
+			a[i][j] -= b[i][j]
+		}
+	}
+	return a
+}
+
+func legacyMultiply(a [][]float64, b [][]float64) [][]float64 {
+	for i := range a {
+		for j := range a[i] {
+			a[i][j] *= b[i][j]
+		}
+	}
+	return a
+}
+
+func legacyScalarMultiply(scalar float64, a [][]float64) [][]float64 {
+	for i := range a {
+		for j := range a[i] {
+			a[i][j] *= scalar
+		}
+	}
+	return a
+}
+
+// adds the column vector b to every column of a
+func legacyAddVectorToMatrix(a [][]float64, b [][]float64) [][]float64 {
+	for i := range a {
+		for j := range a[i] {
+			a[i][j] += b[i][0]
+		}
+	}
+	return a
+}
+
+func legacyTranspose(a [][]float64) [][]float64 {
+	rows := len(a)
+	cols := len(a[0])
+	aT := make([][]float64, cols)
+	for i := 0; i < cols; i++ {
+		aT[i] = make([]float64, rows)
+		for j := 0; j < rows; j++ {
+			aT[i][j] = a[j][i]
+		}
+	}
+	return aT
+}
+
+func legacyReLU(a [][]float64) [][]float64 {
+	for i := range a {
+		for j := range a[i] {
+			if a[i][j] < 0 {
+				a[i][j] = 0
+			}
+		}
+	}
+	return a
+}
+
+func legacyDerivativeReLU(a [][]float64) [][]float64 {
+	for i := range a {
+		for j := range a[i] {
+			if a[i][j] <= 0 {
+				a[i][j] = 0
+			} else {
+				a[i][j] = 1
+			}
+		}
+	}
+	return a
+}
+
+// the original softmax, without the max subtraction: it overflows for large logits
+func legacySoftmax(matrix [][]float64) [][]float64 {
+	rows := len(matrix)
+	cols := len(matrix[0])
+	softmaxed := make([][]float64, rows)
+	for i := 0; i < rows; i++ {
+		softmaxed[i] = make([]float64, cols)
+	}
+	for j := 0; j < cols; j++ {
+		colSum := 0.0
+		for i := 0; i < rows; i++ {
+			colSum += math.Exp(matrix[i][j])
+		}
+		for i := 0; i < rows; i++ {
+			softmaxed[i][j] = math.Exp(matrix[i][j]) / colSum
+			if math.IsNaN(softmaxed[i][j]) {
+				softmaxed[i][j] = 0
+			}
+		}
+	}
+	return softmaxed
+}
+
+func legacySumRows(matrix [][]float64) [][]float64 {
+	sums := make([][]float64, 0)
+	for i := 0; i < len(matrix); i++ {
+		sum := 0.0
+		for j := 0; j < len(matrix[i]); j++ {
+			sum += matrix[i][j]
+		}
+		sums = append(sums, []float64{sum})
+	}
+	return sums
+}
+
+// forward and back propagation through ReLU hidden layers and a softmax output,
+// with the cross-entropy output gradient (a - y) the Matrix version uses
+func legacyGradients(weights [][][]float64, biases [][][]float64, x [][]float64, y []float64) ([][][]float64, [][][]float64) {
+	layers := len(weights)
+	zs := make([][][]float64, layers)
+	as := make([][][]float64, layers+1)
+	as[0] = x
+	for l := 0; l < layers; l++ {
+		zs[l] = legacyAddVectorToMatrix(legacyDot(weights[l], as[l]), biases[l])
+		if l == layers-1 {
+			as[l+1] = legacySoftmax(zs[l])
+		} else {
+			as[l+1] = legacyReLU(legacyClone(zs[l]))
+		}
+	}
+
+	m := float64(len(y))
+	dw := make([][][]float64, layers)
+	db := make([][][]float64, layers)
+	oneHot := make([][]float64, len(y))
+	for i := range oneHot {
+		oneHot[i] = make([]float64, len(as[layers]))
+		oneHot[i][int(y[i])] = 1
+	}
+	dz := legacySubtract(legacyClone(as[layers]), legacyTranspose(oneHot))
+	for l := layers - 1; l >= 0; l-- {
+		dw[l] = legacyScalarMultiply(1/m, legacyDot(dz, legacyTranspose(as[l])))
+		db[l] = legacyScalarMultiply(1/m, legacySumRows(dz))
+		if l > 0 {
+			dz = legacyMultiply(legacyDot(legacyTranspose(weights[l]), dz), legacyDerivativeReLU(legacyClone(zs[l-1])))
+		}
+	}
+	return dw, db
+}
+
+func toSlices(matrices []*Matrix) [][][]float64 {
+	res := make([][][]float64, len(matrices))
+	for i, m := range matrices {
+		res[i] = m.ToSlices()
+	}
+	return res
+}
+
+// the Matrix port must compute the same gradients as the code it replaced before its speed means anything
+func TestBackwardMatchesSlices(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, sizes := range [][]int{{784, 10, 10}, {20, 16, 8, 5}} {
+		net := NewNetwork(sizes, UniformInit, rng)
+		x, y := randomBatch(sizes, 50, rng)
+		dw, db := legacyGradients(toSlices(net.Weights), toSlices(net.Biases), x.ToSlices(), y)
+		zs, as := net.Forward(x)
+		dwMatrix, dbMatrix := net.Backward(zs, as, y)
+		for l := range dw {
+			for _, pair := range []struct {
+				slices [][]float64
+				matrix *Matrix
+			}{{dw[l], dwMatrix[l]}, {db[l], dbMatrix[l]}} {
+				for i := range pair.slices {
+					for j, v := range pair.slices[i] {
+						if math.Abs(v-pair.matrix.At(i, j)) > 1e-12 {
+							t.Fatalf("%v layer %d: gradient (%d, %d) is %v, the [][]float64 code gives %v", sizes, l, i, j, pair.matrix.At(i, j), v)
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+// training one network reuses the same buffers for every mini-batch
+// a Workspace owns the activations, the gradients and the current mini-batch of one network;
+// the buffers are sized on the first mini-batch and after that only ever reshaped,
+// so once that warm-up is done an epoch doesn't allocate
+// (with SetGEMMThreads > 1, handing row blocks to the GEMM pool still does)
+
+type Workspace struct {
+	net     *Network
+	zs      []*Matrix // pre-activations of every layer
+	as      []*Matrix // activations; as[0] is the current mini-batch
+	dzs     []*Matrix // dzs[l] is the gradient of the loss with respect to zs[l]
+	dw      []*Matrix
+	db      []*Matrix
+	batches batcher
+}
+
+// a workspace for training net in place
+// a network must not be trained through two workspaces at the same time
+func NewWorkspace(net *Network) *Workspace {
+	layers := net.Layers()
+	ws := &Workspace{
+		net: net,
+		zs:  emptyMatrices(layers),
+		as:  emptyMatrices(layers + 1),
+		dzs: emptyMatrices(layers),
+		dw:  make([]*Matrix, layers),
+		db:  make([]*Matrix, layers),
+	}
+	for l := 0; l < layers; l++ {
+		ws.dw[l] = NewMatrix(net.Weights[l].Rows, net.Weights[l].Cols)
+		ws.db[l] = NewMatrix(net.Biases[l].Rows, 1)
+	}
+	return ws
+}
+
+// one pass over every sample of x: a forward, backward and optimizer step per mini-batch
+// returns the mean training loss over the epoch (every mini-batch's loss is taken before its step)
+// options.Epochs is ignored
+func (ws *Workspace) Epoch(x *Matrix, y []float64, options TrainOptions) float64 {
+	total := 0.0
+	ws.batches.epoch(x, y, options, func(xBatch *Matrix, yBatch []float64) {
+		_, _, loss := ws.Gradients(xBatch, yBatch)
+		total += loss * float64(len(yBatch))
+		options.Optimizer.Step(ws.net, ws.dw, ws.db)
+	})
+	return total / float64(len(y))
+}
+
+// the gradients of the mean cross-entropy loss on (x, y) with respect to every weight and bias, and the loss itself
+// the returned matrices belong to the workspace and are overwritten by the next call
+func (ws *Workspace) Gradients(x *Matrix, y []float64) ([]*Matrix, []*Matrix, float64) {
+	ws.net.logitsInto(x, ws.zs, ws.as)
+	loss := ws.net.lossInto(ws.zs, ws.as, y, ws.dzs)
+	ws.net.backwardInto(ws.zs, ws.as, ws.dzs, ws.dw, ws.db)
+	return ws.dw, ws.db, loss
+}
+
+func emptyMatrices(n int) []*Matrix {
+	matrices := make([]*Matrix, n)
+	for i := range matrices {
+		matrices[i] = &Matrix{}
+	}
+	return matrices
+}
+
+// hands out the mini-batches of every epoch, copying each one into the same buffer
+type batcher struct {
+	order []int // the sample order, reshuffled every epoch
+	x     Matrix
+	y     []float64
+}
+
+// calls step once per mini-batch of one epoch
+// an epoch is one pass over every sample (column) of x and a step is one forward/back/update
+// on a single mini-batch, so an epoch takes ceil(m / BatchSize) steps
+// the last mini-batch of an epoch is smaller if BatchSize doesn't divide m
+// a mini-batch is only valid until step returns
+func (b *batcher) epoch(x *Matrix, y []float64, options TrainOptions, step func(xBatch *Matrix, yBatch []float64)) {
+	m := len(y)
+	batchSize := options.BatchSize
+	if batchSize <= 0 || batchSize >= m {
+		step(x, y) // full batch: one step per epoch on x itself, no copying
+		return
+	}
+
+	if len(b.order) != m {
+		b.order = make([]int, m)
+		for i := range b.order {
+			b.order[i] = i
+		}
+	}
+	order := b.order
+	if options.Rand != nil {
+		options.Rand.Shuffle(m, func(i, j int) { order[i], order[j] = order[j], order[i] })
+	}
+
+	for lo := 0; lo < m; lo += batchSize {
+		hi := min(lo+batchSize, m)
+		columns := order[lo:hi]
+		x.SelectColumnsInto(columns, b.x.reshape(x.Rows, len(columns)))
+		b.y = b.y[:0]
+		for _, column := range columns {
+			b.y = append(b.y, y[column])
+		}
+		step(&b.x, b.y)
+	}
+}
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// random inputs in [0, 1) and labels for a network with the given sizes
+func randomBatch(sizes []int, samples int, rng *rand.Rand) (*Matrix, []float64) {
+	x := NewMatrix(sizes[0], samples)
+	for i := range x.Data {
+		x.Data[i] = rng.Float64()
+	}
+	y := make([]float64, samples)
+	for j := range y {
+		y[j] = float64(rng.Intn(sizes[len(sizes)-1]))
+	}
+	return x, y
+}
+
+// once its workspace has warmed up, a training epoch must not allocate,
+// whatever the optimizer, the hidden activations or the batch size (an uneven final batch reshapes the buffers)
+func TestEpochDoesNotAllocate(t *testing.T) {
+	SetGEMMThreads(1) // handing row blocks to the GEMM pool does allocate
+	sizes := []int{16, 12, 8, 10}
+	rng := rand.New(rand.NewSource(1))
+	x, y := randomBatch(sizes, 250, rng)
+	for _, activation := range ActivationNames {
+		for _, optimizerName := range OptimizerNames {
+			for _, batchSize := range []int{0, 100} {
+				net := NewNetwork(sizes, UniformInit, rng)
+				net.Activations, _ = NewActivations([]string{activation}, len(sizes)-2)
+				optimizer, _ := NewOptimizer(optimizerName, 0.01)
+				options := TrainOptions{Optimizer: optimizer, BatchSize: batchSize, Rand: rand.New(rand.NewSource(2))}
+				ws := NewWorkspace(net)
+				if allocs := testing.AllocsPerRun(3, func() { ws.Epoch(x, y, options) }); allocs > 0 {
+					t.Errorf("%s, %s, batches of %d: %.0f allocations per epoch after warm-up", activation, optimizerName, batchSize, allocs)
+				}
+			}
+		}
+	}
+}